@@ -95,6 +95,19 @@ func hexEnvConfig(b *[]securityKey, name string) error {
 	return nil
 }
 
+func hexKeyEnvConfig(b *securityKey, name string) error {
+	if env := os.Getenv(name); len(env) > 0 {
+		key, err := hex.DecodeString(env)
+		if err != nil {
+			return fmt.Errorf("%s expected to be hex-encoded string", name)
+		}
+
+		*b = key
+	}
+
+	return nil
+}
+
 func hexFileConfig(b *[]securityKey, filepath string) error {
 	if len(filepath) == 0 {
 		return nil
@@ -182,6 +195,14 @@ type config struct {
 	TTL                     int
 	CacheControlPassthrough bool
 
+	ResultCacheEnabled    bool
+	ResultCacheMemorySize int
+	ResultCacheDiskPath   string
+	ResultCacheDiskSize   int
+	ResultCacheTTL        int
+
+	RequestCoalescingEnabled bool
+
 	SoReuseport bool
 
 	PathPrefix string
@@ -196,17 +217,25 @@ type config struct {
 	PngInterlaced         bool
 	PngQuantize           bool
 	PngQuantizationColors int
+	AvifSpeed             int
+	JxlEffort             int
+	JxlLossless           bool
 	Quality               int
 	GZipCompression       int
 	StripMetadata         bool
 
 	EnableWebpDetection bool
 	EnforceWebp         bool
+	EnableAvifDetection bool
+	EnforceAvif         bool
+	EnableJxlDetection  bool
+	EnforceJxl          bool
 	EnableClientHints   bool
 
 	SkipProcessingFormats []imageType
 
 	UseLinearColorspace bool
+	AutoRotate          bool
 	DisableShrinkOnLoad bool
 
 	Keys          []securityKey
@@ -214,6 +243,8 @@ type config struct {
 	AllowInsecure bool
 	SignatureSize int
 
+	SourceURLEncryptionKey securityKey
+
 	Secret string
 
 	AllowOrigin string
@@ -233,6 +264,8 @@ type config struct {
 
 	ETagEnabled bool
 
+	DominantColorHeader bool
+
 	BaseURL string
 
 	Presets     presets
@@ -243,6 +276,9 @@ type config struct {
 	WatermarkURL     string
 	WatermarkOpacity float64
 
+	WatermarksCacheSize int
+	WatermarksCacheTTL  int
+
 	FallbackImageData string
 	FallbackImagePath string
 	FallbackImageURL  string
@@ -278,16 +314,24 @@ var conf = config{
 	DownloadTimeout:                5,
 	Concurrency:                    runtime.NumCPU() * 2,
 	TTL:                            3600,
+	ResultCacheMemorySize:          64 * 1024 * 1024,
+	ResultCacheDiskSize:            1024 * 1024 * 1024,
+	ResultCacheTTL:                 3600,
 	MaxSrcResolution:               16800000,
 	MaxAnimationFrames:             1,
 	MaxSvgCheckBytes:               32 * 1024,
 	SignatureSize:                  32,
 	PngQuantizationColors:          256,
+	AvifSpeed:                      5,
+	JxlEffort:                      7,
+	AutoRotate:                     true,
 	Quality:                        80,
 	StripMetadata:                  true,
 	UserAgent:                      fmt.Sprintf("imgproxy/%s", version),
 	Presets:                        make(presets),
 	WatermarkOpacity:               1,
+	WatermarksCacheSize:            256,
+	WatermarksCacheTTL:             3600,
 	BugsnagStage:                   "production",
 	HoneybadgerEnv:                 "production",
 	SentryEnvironment:              "production",
@@ -319,6 +363,14 @@ func configure() error {
 	intEnvConfig(&conf.TTL, "IMGPROXY_TTL")
 	boolEnvConfig(&conf.CacheControlPassthrough, "IMGPROXY_CACHE_CONTROL_PASSTHROUGH")
 
+	boolEnvConfig(&conf.ResultCacheEnabled, "IMGPROXY_USE_RESULT_CACHE")
+	intEnvConfig(&conf.ResultCacheMemorySize, "IMGPROXY_RESULT_CACHE_MEMORY_SIZE")
+	strEnvConfig(&conf.ResultCacheDiskPath, "IMGPROXY_RESULT_CACHE_DISK_PATH")
+	intEnvConfig(&conf.ResultCacheDiskSize, "IMGPROXY_RESULT_CACHE_DISK_SIZE")
+	intEnvConfig(&conf.ResultCacheTTL, "IMGPROXY_RESULT_CACHE_TTL")
+
+	boolEnvConfig(&conf.RequestCoalescingEnabled, "IMGPROXY_USE_REQUEST_COALESCING")
+
 	boolEnvConfig(&conf.SoReuseport, "IMGPROXY_SO_REUSEPORT")
 
 	strEnvConfig(&conf.PathPrefix, "IMGPROXY_PATH_PREFIX")
@@ -340,17 +392,25 @@ func configure() error {
 	boolEnvConfig(&conf.PngInterlaced, "IMGPROXY_PNG_INTERLACED")
 	boolEnvConfig(&conf.PngQuantize, "IMGPROXY_PNG_QUANTIZE")
 	intEnvConfig(&conf.PngQuantizationColors, "IMGPROXY_PNG_QUANTIZATION_COLORS")
+	intEnvConfig(&conf.AvifSpeed, "IMGPROXY_AVIF_SPEED")
+	intEnvConfig(&conf.JxlEffort, "IMGPROXY_JXL_EFFORT")
+	boolEnvConfig(&conf.JxlLossless, "IMGPROXY_JXL_LOSSLESS")
 	intEnvConfig(&conf.Quality, "IMGPROXY_QUALITY")
 	intEnvConfig(&conf.GZipCompression, "IMGPROXY_GZIP_COMPRESSION")
 	boolEnvConfig(&conf.StripMetadata, "IMGPROXY_STRIP_METADATA")
 
 	boolEnvConfig(&conf.EnableWebpDetection, "IMGPROXY_ENABLE_WEBP_DETECTION")
 	boolEnvConfig(&conf.EnforceWebp, "IMGPROXY_ENFORCE_WEBP")
+	boolEnvConfig(&conf.EnableAvifDetection, "IMGPROXY_ENABLE_AVIF_DETECTION")
+	boolEnvConfig(&conf.EnforceAvif, "IMGPROXY_ENFORCE_AVIF")
+	boolEnvConfig(&conf.EnableJxlDetection, "IMGPROXY_ENABLE_JXL_DETECTION")
+	boolEnvConfig(&conf.EnforceJxl, "IMGPROXY_ENFORCE_JXL")
 	boolEnvConfig(&conf.EnableClientHints, "IMGPROXY_ENABLE_CLIENT_HINTS")
 
 	imageTypesEnvConfig(&conf.SkipProcessingFormats, "IMGPROXY_SKIP_PROCESSING_FORMATS")
 
 	boolEnvConfig(&conf.UseLinearColorspace, "IMGPROXY_USE_LINEAR_COLORSPACE")
+	boolEnvConfig(&conf.AutoRotate, "IMGPROXY_AUTO_ROTATE")
 	boolEnvConfig(&conf.DisableShrinkOnLoad, "IMGPROXY_DISABLE_SHRINK_ON_LOAD")
 
 	if err := hexEnvConfig(&conf.Keys, "IMGPROXY_KEY"); err != nil {
@@ -361,6 +421,10 @@ func configure() error {
 	}
 	intEnvConfig(&conf.SignatureSize, "IMGPROXY_SIGNATURE_SIZE")
 
+	if err := hexKeyEnvConfig(&conf.SourceURLEncryptionKey, "IMGPROXY_SOURCE_URL_ENCRYPTION_KEY"); err != nil {
+		return err
+	}
+
 	if err := hexFileConfig(&conf.Keys, *keyPath); err != nil {
 		return err
 	}
@@ -388,6 +452,8 @@ func configure() error {
 
 	boolEnvConfig(&conf.ETagEnabled, "IMGPROXY_USE_ETAG")
 
+	boolEnvConfig(&conf.DominantColorHeader, "IMGPROXY_DOMINANT_COLOR_HEADER")
+
 	strEnvConfig(&conf.BaseURL, "IMGPROXY_BASE_URL")
 
 	if err := presetEnvConfig(conf.Presets, "IMGPROXY_PRESETS"); err != nil {
@@ -402,6 +468,8 @@ func configure() error {
 	strEnvConfig(&conf.WatermarkPath, "IMGPROXY_WATERMARK_PATH")
 	strEnvConfig(&conf.WatermarkURL, "IMGPROXY_WATERMARK_URL")
 	floatEnvConfig(&conf.WatermarkOpacity, "IMGPROXY_WATERMARK_OPACITY")
+	intEnvConfig(&conf.WatermarksCacheSize, "IMGPROXY_WATERMARKS_CACHE_SIZE")
+	intEnvConfig(&conf.WatermarksCacheTTL, "IMGPROXY_WATERMARKS_CACHE_TTL")
 
 	strEnvConfig(&conf.FallbackImageData, "IMGPROXY_FALLBACK_IMAGE_DATA")
 	strEnvConfig(&conf.FallbackImagePath, "IMGPROXY_FALLBACK_IMAGE_PATH")
@@ -443,6 +511,10 @@ func configure() error {
 		return fmt.Errorf("Signature size should be within 1 and 32, now - %d\n", conf.SignatureSize)
 	}
 
+	if l := len(conf.SourceURLEncryptionKey); l != 0 && l != 16 && l != 24 && l != 32 {
+		return fmt.Errorf("Source URL encryption key should be 16, 24, or 32 bytes long, now - %d\n", l)
+	}
+
 	if len(conf.Bind) == 0 {
 		return fmt.Errorf("Bind address is not defined")
 	}
@@ -474,6 +546,23 @@ func configure() error {
 		return fmt.Errorf("TTL should be greater than 0, now - %d\n", conf.TTL)
 	}
 
+	if conf.ResultCacheMemorySize < 0 {
+		return fmt.Errorf("Result cache memory size should be greater than or equal to 0, now - %d\n", conf.ResultCacheMemorySize)
+	}
+
+	if conf.ResultCacheDiskSize < 0 {
+		return fmt.Errorf("Result cache disk size should be greater than or equal to 0, now - %d\n", conf.ResultCacheDiskSize)
+	}
+
+	if conf.ResultCacheTTL <= 0 {
+		return fmt.Errorf("Result cache TTL should be greater than 0, now - %d\n", conf.ResultCacheTTL)
+	}
+
+	if conf.ResultCacheEnabled && conf.ResultCacheMemorySize == 0 && (len(conf.ResultCacheDiskPath) == 0 || conf.ResultCacheDiskSize == 0) {
+		logWarning("Both result cache tiers are disabled, so result cache is disabled")
+		conf.ResultCacheEnabled = false
+	}
+
 	if conf.MaxSrcDimension < 0 {
 		return fmt.Errorf("Max src dimension should be greater than or equal to 0, now - %d\n", conf.MaxSrcDimension)
 	} else if conf.MaxSrcDimension > 0 {
@@ -498,6 +587,18 @@ func configure() error {
 		return fmt.Errorf("Png quantization colors can't be greater than 256, now - %d\n", conf.PngQuantizationColors)
 	}
 
+	if conf.AvifSpeed < 0 {
+		return fmt.Errorf("Avif speed should be greater than or equal to 0, now - %d\n", conf.AvifSpeed)
+	} else if conf.AvifSpeed > 8 {
+		return fmt.Errorf("Avif speed can't be greater than 8, now - %d\n", conf.AvifSpeed)
+	}
+
+	if conf.JxlEffort < 1 {
+		return fmt.Errorf("JPEG XL effort should be greater than 0, now - %d\n", conf.JxlEffort)
+	} else if conf.JxlEffort > 9 {
+		return fmt.Errorf("JPEG XL effort can't be greater than 9, now - %d\n", conf.JxlEffort)
+	}
+
 	if conf.Quality <= 0 {
 		return fmt.Errorf("Quality should be greater than 0, now - %d\n", conf.Quality)
 	} else if conf.Quality > 100 {
@@ -545,6 +646,14 @@ func configure() error {
 		return fmt.Errorf("Watermark opacity should be less than or equal to 1")
 	}
 
+	if conf.WatermarksCacheSize < 0 {
+		return fmt.Errorf("Watermarks cache size should be greater than or equal to 0, now - %d\n", conf.WatermarksCacheSize)
+	}
+
+	if conf.WatermarksCacheTTL <= 0 {
+		return fmt.Errorf("Watermarks cache TTL should be greater than 0, now - %d\n", conf.WatermarksCacheTTL)
+	}
+
 	if len(conf.PrometheusBind) > 0 && conf.PrometheusBind == conf.Bind {
 		return fmt.Errorf("Can't use the same binding for the main server and Prometheus")
 	}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
@@ -10,6 +12,9 @@ import (
 var (
 	errInvalidSignature         = errors.New("Invalid signature")
 	errInvalidSignatureEncoding = errors.New("Invalid signature encoding")
+
+	errSourceURLEncryptionDisabled = errors.New("Source URL encryption key is not configured")
+	errInvalidEncryptedSourceURL   = errors.New("Invalid encrypted source URL")
 )
 
 type securityKey []byte
@@ -39,3 +44,31 @@ func signatureFor(str string, pairInd int) []byte {
 	}
 	return expectedMAC
 }
+
+func decryptSourceURL(encrypted []byte) (string, error) {
+	if len(conf.SourceURLEncryptionKey) == 0 {
+		return "", errSourceURLEncryptionDisabled
+	}
+
+	block, err := aes.NewCipher(conf.SourceURLEncryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := aesgcm.NonceSize()
+	if len(encrypted) <= nonceSize {
+		return "", errInvalidEncryptedSourceURL
+	}
+
+	plain, err := aesgcm.Open(nil, encrypted[:nonceSize], encrypted[nonceSize:], nil)
+	if err != nil {
+		return "", errInvalidEncryptedSourceURL
+	}
+
+	return string(plain), nil
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -47,6 +51,52 @@ func (s *CryptTestSuite) TestValidatePathMultiplePairs() {
 	assert.Error(s.T(), err)
 }
 
+func (s *CryptTestSuite) TestDecryptSourceURL() {
+	conf.SourceURLEncryptionKey = securityKey("0123456789abcdef")
+
+	encrypted := encryptSourceURLForTest("http://images.dev/lorem/ipsum.jpg")
+
+	imageURL, err := decryptSourceURL(encrypted)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "http://images.dev/lorem/ipsum.jpg", imageURL)
+}
+
+func (s *CryptTestSuite) TestDecryptSourceURLInvalid() {
+	conf.SourceURLEncryptionKey = securityKey("0123456789abcdef")
+
+	encrypted := encryptSourceURLForTest("http://images.dev/lorem/ipsum.jpg")
+	encrypted[len(encrypted)-1] ^= 0xff
+
+	_, err := decryptSourceURL(encrypted)
+	assert.Error(s.T(), err)
+}
+
+func (s *CryptTestSuite) TestDecryptSourceURLDisabled() {
+	encrypted := encryptSourceURLForTest("http://images.dev/lorem/ipsum.jpg")
+
+	_, err := decryptSourceURL(encrypted)
+	assert.Equal(s.T(), errSourceURLEncryptionDisabled, err)
+}
+
+func encryptSourceURLForTest(imageURL string) []byte {
+	block, err := aes.NewCipher(securityKey("0123456789abcdef"))
+	if err != nil {
+		panic(err)
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+
+	nonce := make([]byte, aesgcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		panic(err)
+	}
+
+	return aesgcm.Seal(nonce, nonce, []byte(imageURL), nil)
+}
+
 func TestCrypt(t *testing.T) {
 	suite.Run(t, new(CryptTestSuite))
 }
@@ -103,25 +103,27 @@ func checkDimensions(width, height int) error {
 	return nil
 }
 
-func checkTypeAndDimensions(r io.Reader) (imageType, error) {
+// checkTypeAndDimensions checks the source image type and dimensions
+// and returns its type and number of pages
+func checkTypeAndDimensions(r io.Reader) (imageType, int, error) {
 	meta, err := imagemeta.DecodeMeta(r)
 	if err == imagemeta.ErrFormat {
-		return imageTypeUnknown, errSourceImageTypeNotSupported
+		return imageTypeUnknown, 0, errSourceImageTypeNotSupported
 	}
 	if err != nil {
-		return imageTypeUnknown, newUnexpectedError(err.Error(), 0)
+		return imageTypeUnknown, 0, newUnexpectedError(err.Error(), 0)
 	}
 
 	imgtype, imgtypeOk := imageTypes[meta.Format()]
 	if !imgtypeOk || !imageTypeLoadSupport(imgtype) {
-		return imageTypeUnknown, errSourceImageTypeNotSupported
+		return imageTypeUnknown, 0, errSourceImageTypeNotSupported
 	}
 
 	if err = checkDimensions(meta.Width(), meta.Height()); err != nil {
-		return imageTypeUnknown, err
+		return imageTypeUnknown, 0, err
 	}
 
-	return imgtype, nil
+	return imgtype, meta.Pages(), nil
 }
 
 func readAndCheckImage(r io.Reader, contentLength int) (*imageData, error) {
@@ -136,7 +138,7 @@ func readAndCheckImage(r io.Reader, contentLength int) (*imageData, error) {
 		r = &limitReader{r: r, left: conf.MaxSrcFileSize}
 	}
 
-	imgtype, err := checkTypeAndDimensions(io.TeeReader(r, buf))
+	imgtype, pages, err := checkTypeAndDimensions(io.TeeReader(r, buf))
 	if err != nil {
 		cancel()
 		return nil, err
@@ -147,7 +149,7 @@ func readAndCheckImage(r io.Reader, contentLength int) (*imageData, error) {
 		return nil, newError(404, err.Error(), msgSourceImageIsUnreachable)
 	}
 
-	return &imageData{buf.Bytes(), imgtype, cancel}, nil
+	return &imageData{Data: buf.Bytes(), Type: imgtype, Pages: pages, cancel: cancel}, nil
 }
 
 func requestImage(imageURL string) (*http.Response, error) {
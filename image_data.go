@@ -12,6 +12,9 @@ type imageData struct {
 	Data []byte
 	Type imageType
 
+	// Number of pages or animation frames. Zero when unknown
+	Pages int
+
 	cancel context.CancelFunc
 }
 
@@ -59,12 +62,12 @@ func base64ImageData(encoded, desc string) (*imageData, error) {
 		return nil, fmt.Errorf("Can't decode %s data: %s", desc, err)
 	}
 
-	imgtype, err := checkTypeAndDimensions(bytes.NewReader(data))
+	imgtype, pages, err := checkTypeAndDimensions(bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("Can't decode %s: %s", desc, err)
 	}
 
-	return &imageData{Data: data, Type: imgtype}, nil
+	return &imageData{Data: data, Type: imgtype, Pages: pages}, nil
 }
 
 func fileImageData(path, desc string) (*imageData, error) {
@@ -26,6 +26,15 @@ const (
 	imageTypeHEIC    = imageType(C.HEIC)
 	imageTypeBMP     = imageType(C.BMP)
 	imageTypeTIFF    = imageType(C.TIFF)
+	imageTypeAVIF    = imageType(C.AVIF)
+	imageTypeJXL     = imageType(C.JXL)
+	imageTypePDF     = imageType(C.PDF)
+
+	imageTypeBLURHASH  = imageType(C.BLURHASH)
+	imageTypeTHUMBHASH = imageType(C.THUMBHASH)
+
+	// imageTypePALETTE can't be requested via the format option, use the palette option instead
+	imageTypePALETTE = imageType(C.PALETTE)
 
 	contentDispositionFilenameFallback = "image"
 )
@@ -42,6 +51,12 @@ var (
 		"heic": imageTypeHEIC,
 		"bmp":  imageTypeBMP,
 		"tiff": imageTypeTIFF,
+		"avif": imageTypeAVIF,
+		"jxl":  imageTypeJXL,
+		"pdf":  imageTypePDF,
+
+		"blurhash":  imageTypeBLURHASH,
+		"thumbhash": imageTypeTHUMBHASH,
 	}
 
 	mimes = map[imageType]string{
@@ -54,6 +69,14 @@ var (
 		imageTypeHEIC: "image/heif",
 		imageTypeBMP:  "image/bmp",
 		imageTypeTIFF: "image/tiff",
+		imageTypeAVIF: "image/avif",
+		imageTypeJXL:  "image/jxl",
+		imageTypePDF:  "application/pdf",
+
+		imageTypeBLURHASH:  "text/plain",
+		imageTypeTHUMBHASH: "text/plain",
+
+		imageTypePALETTE: "application/json",
 	}
 
 	contentDispositionsFmt = map[imageType]string{
@@ -66,6 +89,14 @@ var (
 		imageTypeHEIC: "inline; filename=\"%s.heic\"",
 		imageTypeBMP:  "inline; filename=\"%s.bmp\"",
 		imageTypeTIFF: "inline; filename=\"%s.tiff\"",
+		imageTypeAVIF: "inline; filename=\"%s.avif\"",
+		imageTypeJXL:  "inline; filename=\"%s.jxl\"",
+		imageTypePDF:  "inline; filename=\"%s.pdf\"",
+
+		imageTypeBLURHASH:  "inline; filename=\"%s.txt\"",
+		imageTypeTHUMBHASH: "inline; filename=\"%s.txt\"",
+
+		imageTypePALETTE: "inline; filename=\"%s.json\"",
 	}
 )
 
@@ -119,5 +150,11 @@ func (it imageType) ContentDispositionFromURL(imageURL string) string {
 }
 
 func (it imageType) SupportsAlpha() bool {
-	return it != imageTypeJPEG && it != imageTypeBMP
+	return it != imageTypeJPEG && it != imageTypeBMP && it != imageTypeBLURHASH
+}
+
+// IsPlaceholder returns true if the type is a low-quality image placeholder hash
+// rather than an actual image format
+func (it imageType) IsPlaceholder() bool {
+	return it == imageTypeBLURHASH || it == imageTypeTHUMBHASH
 }
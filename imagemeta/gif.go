@@ -2,10 +2,93 @@ package imagemeta
 
 import (
 	"io"
+	"io/ioutil"
 )
 
+const (
+	gifImageDescriptor = 0x2c
+	gifExtension       = 0x21
+	gifTrailer         = 0x3b
+)
+
+func gifSkipColorTable(r io.Reader, flags byte) error {
+	if flags&0x80 == 0 {
+		return nil
+	}
+
+	_, err := io.CopyN(ioutil.Discard, r, 3*(int64(1)<<((flags&7)+1)))
+	return err
+}
+
+func gifSkipSubBlocks(r io.Reader) error {
+	var size [1]byte
+
+	for {
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return err
+		}
+
+		if size[0] == 0 {
+			return nil
+		}
+
+		if _, err := io.CopyN(ioutil.Discard, r, int64(size[0])); err != nil {
+			return err
+		}
+	}
+}
+
+// gifCountFrames counts image descriptors of GIF. r should point right after the header.
+// If the data is malformed or truncated, the count is unknown and 0 is returned
+func gifCountFrames(r io.Reader, flags byte) int {
+	var tmp [9]byte
+
+	frames := 0
+
+	if gifSkipColorTable(r, flags) != nil {
+		return 0
+	}
+
+	for {
+		if _, err := io.ReadFull(r, tmp[:1]); err != nil {
+			return 0
+		}
+
+		switch tmp[0] {
+		case gifImageDescriptor:
+			frames++
+
+			// Position, size, and flags; then LZW minimum code size goes after the color table
+			if _, err := io.ReadFull(r, tmp[:9]); err != nil {
+				return 0
+			}
+			if gifSkipColorTable(r, tmp[8]) != nil {
+				return 0
+			}
+			if _, err := io.ReadFull(r, tmp[:1]); err != nil {
+				return 0
+			}
+			if gifSkipSubBlocks(r) != nil {
+				return 0
+			}
+		case gifExtension:
+			if _, err := io.ReadFull(r, tmp[:1]); err != nil {
+				return 0
+			}
+			if gifSkipSubBlocks(r) != nil {
+				return 0
+			}
+		case gifTrailer:
+			return frames
+		default:
+			// Malformed data
+			return 0
+		}
+	}
+}
+
 func DecodeGifMeta(r io.Reader) (Meta, error) {
-	var tmp [10]byte
+	var tmp [13]byte
 
 	_, err := io.ReadFull(r, tmp[:])
 	if err != nil {
@@ -16,6 +99,7 @@ func DecodeGifMeta(r io.Reader) (Meta, error) {
 		format: "gif",
 		width:  int(tmp[6]) + int(tmp[7])<<8,
 		height: int(tmp[8]) + int(tmp[9])<<8,
+		pages:  gifCountFrames(r, tmp[10]),
 	}, nil
 }
 
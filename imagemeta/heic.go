@@ -11,10 +11,18 @@ import (
 const heicBoxHeaderSize = int64(8)
 
 var heicBrand = []byte("heic")
+var avifBrand = []byte("avif")
 var heicPict = []byte("pict")
 
 type heicDimensionsData struct {
+	Format        string
 	Width, Height int64
+
+	// Image items and items that are not top-level images (thumbnails, grid tiles, etc)
+	Items, HiddenItems map[uint32]bool
+
+	// Set when some item info entries can't be read, so the items list is incomplete
+	ItemsUnknown bool
 }
 
 func (d *heicDimensionsData) IsFilled() bool {
@@ -40,7 +48,7 @@ func heicReadBoxData(r io.Reader, boxDataSize int64) (b []byte, err error) {
 	return
 }
 
-func heicReadFtyp(r io.Reader, boxDataSize int64) error {
+func heicReadFtyp(d *heicDimensionsData, r io.Reader, boxDataSize int64) error {
 	if boxDataSize < 8 {
 		return errors.New("Invalid ftyp data")
 	}
@@ -51,18 +59,29 @@ func heicReadFtyp(r io.Reader, boxDataSize int64) error {
 	}
 
 	if bytes.Equal(data[0:4], heicBrand) {
+		d.Format = "heic"
+		return nil
+	}
+
+	if bytes.Equal(data[0:4], avifBrand) {
+		d.Format = "avif"
 		return nil
 	}
 
 	if boxDataSize >= 12 {
 		for i := int64(8); i < boxDataSize; i += 4 {
 			if bytes.Equal(data[i:i+4], heicBrand) {
+				d.Format = "heic"
+				return nil
+			}
+			if bytes.Equal(data[i:i+4], avifBrand) {
+				d.Format = "avif"
 				return nil
 			}
 		}
 	}
 
-	return errors.New("Image is not compatible with heic")
+	return errors.New("Image is not compatible with heic/avif")
 }
 
 func heicReadMeta(d *heicDimensionsData, r io.Reader, boxDataSize int64) error {
@@ -116,6 +135,140 @@ func heicReadIspe(r io.Reader, boxDataSize int64) (w, h int64, err error) {
 	return
 }
 
+// heicReadItemID reads 16-bit or 32-bit item ID depending on the box version
+func heicReadItemID(data []byte, version byte) (id uint32, size int, ok bool) {
+	if version < 1 {
+		if len(data) < 2 {
+			return 0, 0, false
+		}
+		return uint32(binary.BigEndian.Uint16(data)), 2, true
+	}
+
+	if len(data) < 4 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(data), 4, true
+}
+
+// heicReadSubBoxes calls fn for each box in data
+func heicReadSubBoxes(data []byte, fn func(boxType string, boxData []byte)) {
+	for len(data) >= 8 {
+		boxSize := int(binary.BigEndian.Uint32(data[0:4]))
+		if boxSize < 8 || boxSize > len(data) {
+			return
+		}
+
+		fn(string(data[4:8]), data[8:boxSize])
+
+		data = data[boxSize:]
+	}
+}
+
+func heicReadIinf(d *heicDimensionsData, r io.Reader, boxDataSize int64) error {
+	data, err := heicReadBoxData(r, boxDataSize)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < 6 {
+		return errors.New("Invalid iinf data")
+	}
+
+	// Skip version, flags, and entry count
+	offset := 6
+	if data[0] > 0 {
+		offset = 8
+	}
+
+	if len(data) < offset {
+		return errors.New("Invalid iinf data")
+	}
+
+	heicReadSubBoxes(data[offset:], func(boxType string, boxData []byte) {
+		if boxType != "infe" {
+			return
+		}
+
+		// Only infe version 2+ contains item type
+		if len(boxData) < 4 || boxData[0] < 2 {
+			d.ItemsUnknown = true
+			return
+		}
+
+		// infe version 2 has 16-bit item ID
+		id, size, ok := heicReadItemID(boxData[4:], boxData[0]-2)
+		if !ok || len(boxData) < 4+size+6 {
+			d.ItemsUnknown = true
+			return
+		}
+
+		// Skip item protection index
+		switch string(boxData[4+size+2 : 4+size+6]) {
+		case "hvc1", "av01", "grid", "iden", "iovl":
+			d.Items[id] = true
+		}
+	})
+
+	return nil
+}
+
+func heicReadIref(d *heicDimensionsData, r io.Reader, boxDataSize int64) error {
+	data, err := heicReadBoxData(r, boxDataSize)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < 4 {
+		return errors.New("Invalid iref data")
+	}
+
+	version := data[0]
+
+	heicReadSubBoxes(data[4:], func(refType string, refData []byte) {
+		fromID, size, ok := heicReadItemID(refData, version)
+		if !ok || len(refData) < size+2 {
+			return
+		}
+
+		switch refType {
+		case "thmb", "auxl":
+			// Thumbnails and auxiliary images like alpha and depth maps
+			d.HiddenItems[fromID] = true
+		case "dimg":
+			// Grid tiles and other derived image inputs
+			refCount := int(binary.BigEndian.Uint16(refData[size : size+2]))
+			toIDs := refData[size+2:]
+
+			for i := 0; i < refCount; i++ {
+				toID, toSize, ok := heicReadItemID(toIDs, version)
+				if !ok {
+					return
+				}
+
+				d.HiddenItems[toID] = true
+				toIDs = toIDs[toSize:]
+			}
+		}
+	})
+
+	return nil
+}
+
+// Pages returns the number of top-level images or 0 if it's unknown
+func (d *heicDimensionsData) Pages() (pages int) {
+	if d.ItemsUnknown {
+		return 0
+	}
+
+	for id := range d.Items {
+		if !d.HiddenItems[id] {
+			pages++
+		}
+	}
+
+	return
+}
+
 func heicReadBoxes(d *heicDimensionsData, r io.Reader) error {
 	for {
 		boxType, boxDataSize, err := heicReadBoxHeader(r)
@@ -132,7 +285,7 @@ func heicReadBoxes(d *heicDimensionsData, r io.Reader) error {
 
 		switch boxType {
 		case "ftyp":
-			if err := heicReadFtyp(r, boxDataSize); err != nil {
+			if err := heicReadFtyp(d, r, boxDataSize); err != nil {
 				return err
 			}
 		case "meta":
@@ -147,6 +300,14 @@ func heicReadBoxes(d *heicDimensionsData, r io.Reader) error {
 			if err := heicReadHldr(r, boxDataSize); err != nil {
 				return nil
 			}
+		case "iinf":
+			if err := heicReadIinf(d, r, boxDataSize); err != nil {
+				return err
+			}
+		case "iref":
+			if err := heicReadIref(d, r, boxDataSize); err != nil {
+				return err
+			}
 		case "iprp", "ipco":
 			if err := heicReadBoxes(d, io.LimitReader(r, boxDataSize)); err != nil && err != io.EOF {
 				return err
@@ -170,16 +331,25 @@ func heicReadBoxes(d *heicDimensionsData, r io.Reader) error {
 }
 
 func DecodeHeicMeta(r io.Reader) (Meta, error) {
-	d := new(heicDimensionsData)
+	d := &heicDimensionsData{
+		Items:       make(map[uint32]bool),
+		HiddenItems: make(map[uint32]bool),
+	}
+
+	if err := heicReadBoxes(d, r); err != nil {
+		if !d.IsFilled() {
+			return nil, err
+		}
 
-	if err := heicReadBoxes(d, r); err != nil && !d.IsFilled() {
-		return nil, err
+		// Dimensions are found but the rest of the meta box is broken
+		d.ItemsUnknown = true
 	}
 
 	return &meta{
-		format: "heic",
+		format: d.Format,
 		width:  int(d.Width),
 		height: int(d.Height),
+		pages:  d.Pages(),
 	}, nil
 }
 
@@ -192,4 +362,5 @@ func init() {
 	RegisterFormat("????ftyphevm", DecodeHeicMeta)
 	RegisterFormat("????ftyphevs", DecodeHeicMeta)
 	RegisterFormat("????ftypmif1", DecodeHeicMeta)
+	RegisterFormat("????ftypavif", DecodeHeicMeta)
 }
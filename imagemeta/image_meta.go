@@ -12,6 +12,7 @@ type Meta interface {
 	Format() string
 	Width() int
 	Height() int
+	Pages() int
 }
 
 type DecodeMetaFunc func(io.Reader) (Meta, error)
@@ -19,6 +20,7 @@ type DecodeMetaFunc func(io.Reader) (Meta, error)
 type meta struct {
 	format        string
 	width, height int
+	pages         int
 }
 
 func (m *meta) Format() string {
@@ -33,6 +35,12 @@ func (m *meta) Height() int {
 	return m.height
 }
 
+// Pages returns the number of pages or animation frames.
+// Returns 0 if the number is unknown or the format doesn't support pagination
+func (m *meta) Pages() int {
+	return m.pages
+}
+
 type format struct {
 	magic      string
 	decodeMeta DecodeMetaFunc
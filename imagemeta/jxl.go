@@ -0,0 +1,179 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	jxlCodestreamMarker = []byte{0xff, 0x0a}
+	jxlContainerMarker  = []byte{0x00, 0x00, 0x00, 0x0c, 'J', 'X', 'L', ' ', 0x0d, 0x0a, 0x87, 0x0a}
+
+	jxlRatios = [][2]uint64{{1, 1}, {12, 10}, {4, 3}, {3, 2}, {16, 9}, {5, 4}, {2, 1}}
+)
+
+// Size header takes no more than 9 bytes
+const jxlSizeHeaderSize = 9
+
+type JxlFormatError string
+
+func (e JxlFormatError) Error() string { return "invalid JPEG XL format: " + string(e) }
+
+type jxlBitReader struct {
+	buf []byte
+	pos uint
+}
+
+func (br *jxlBitReader) Read(n uint) (uint64, error) {
+	var v uint64
+
+	for i := uint(0); i < n; i++ {
+		idx := int(br.pos >> 3)
+		if idx >= len(br.buf) {
+			return 0, JxlFormatError("unexpected end of size header")
+		}
+
+		v |= uint64((br.buf[idx]>>(br.pos&7))&1) << i
+		br.pos++
+	}
+
+	return v, nil
+}
+
+func (br *jxlBitReader) ReadSize(small bool) (uint64, error) {
+	if small {
+		v, err := br.Read(5)
+		return (v + 1) * 8, err
+	}
+
+	selector, err := br.Read(2)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := br.Read([]uint{9, 13, 18, 30}[selector])
+	return v + 1, err
+}
+
+func jxlReadCodestreamSize(r io.Reader) (width, height int, err error) {
+	var tmp [2 + jxlSizeHeaderSize]byte
+
+	n, err := io.ReadFull(r, tmp[:])
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return 0, 0, err
+	}
+
+	if n < 2 || !bytes.Equal(tmp[:2], jxlCodestreamMarker) {
+		return 0, 0, JxlFormatError("invalid codestream signature")
+	}
+
+	br := jxlBitReader{buf: tmp[2:n]}
+
+	small, err := br.Read(1)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	h, err := br.ReadSize(small == 1)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ratio, err := br.Read(3)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var w uint64
+
+	if ratio == 0 {
+		if w, err = br.ReadSize(small == 1); err != nil {
+			return 0, 0, err
+		}
+	} else {
+		w = h * jxlRatios[ratio-1][0] / jxlRatios[ratio-1][1]
+	}
+
+	return int(w), int(h), nil
+}
+
+func jxlReadContainerSize(r io.Reader) (width, height int, err error) {
+	var header [16]byte
+
+	for {
+		if _, err = io.ReadFull(r, header[:8]); err != nil {
+			return
+		}
+
+		boxType := string(header[4:8])
+		boxDataSize := int64(binary.BigEndian.Uint32(header[0:4])) - 8
+
+		if boxDataSize == -7 {
+			// Box has 64-bit size
+			if _, err = io.ReadFull(r, header[8:16]); err != nil {
+				return
+			}
+			boxDataSize = int64(binary.BigEndian.Uint64(header[8:16])) - 16
+		}
+
+		switch boxType {
+		case "jxlc":
+			return jxlReadCodestreamSize(r)
+		case "jxlp":
+			// Partial codestream box starts with the index
+			if _, err = io.ReadFull(r, header[:4]); err != nil {
+				return
+			}
+			return jxlReadCodestreamSize(r)
+		}
+
+		if boxDataSize < 0 {
+			return 0, 0, JxlFormatError("codestream box not found")
+		}
+
+		if _, err = io.CopyN(ioutil.Discard, r, boxDataSize); err != nil {
+			return
+		}
+	}
+}
+
+func DecodeJxlMeta(r io.Reader) (Meta, error) {
+	var (
+		tmp           [12]byte
+		width, height int
+		err           error
+	)
+
+	rr := asReader(r)
+
+	if b, perr := rr.Peek(2); perr == nil && bytes.Equal(b, jxlCodestreamMarker) {
+		width, height, err = jxlReadCodestreamSize(rr)
+	} else {
+		if _, err = io.ReadFull(rr, tmp[:]); err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(tmp[:], jxlContainerMarker) {
+			return nil, JxlFormatError("not a JPEG XL image")
+		}
+
+		width, height, err = jxlReadContainerSize(rr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &meta{
+		format: "jxl",
+		width:  width,
+		height: height,
+	}, nil
+}
+
+func init() {
+	RegisterFormat(string(jxlCodestreamMarker), DecodeJxlMeta)
+	RegisterFormat(string(jxlContainerMarker), DecodeJxlMeta)
+}
@@ -0,0 +1,300 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type PagesTestSuite struct{ suite.Suite }
+
+type pagesTestCase struct {
+	name  string
+	data  []byte
+	pages int
+}
+
+func (s *PagesTestSuite) checkPages(testCases []pagesTestCase) {
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			meta, err := DecodeMeta(bytes.NewReader(tc.data))
+			require.Nil(s.T(), err)
+
+			assert.Equal(s.T(), tc.pages, meta.Pages())
+		})
+	}
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func be16(v int) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, uint16(v))
+	return b
+}
+
+func be32(v int) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(v))
+	return b
+}
+
+func le16(v int) []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, uint16(v))
+	return b
+}
+
+func le32(v int) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(v))
+	return b
+}
+
+var (
+	gifHeader = concat(
+		[]byte("GIF89a"),
+		le16(2), le16(2), // Width and height
+		[]byte{0x80, 0, 0}, // Global color table of 2 colors, background color, aspect ratio
+		make([]byte, 6),    // Global color table
+	)
+	gifFrame = concat(
+		[]byte{gifImageDescriptor},
+		le16(0), le16(0), le16(2), le16(2),
+		[]byte{0},       // No local color table
+		[]byte{2},       // LZW minimum code size
+		[]byte{1, 0, 0}, // Image data sub-block and terminator
+	)
+	gifGraphicControl = []byte{gifExtension, 0xf9, 4, 0, 10, 0, 0, 0}
+)
+
+func (s *PagesTestSuite) TestGifPages() {
+	s.checkPages([]pagesTestCase{
+		{
+			name:  "Still",
+			data:  concat(gifHeader, gifFrame, []byte{gifTrailer}),
+			pages: 1,
+		},
+		{
+			name: "Animated",
+			data: concat(
+				gifHeader,
+				gifGraphicControl, gifFrame,
+				gifGraphicControl, gifFrame,
+				gifGraphicControl, gifFrame,
+				[]byte{gifTrailer},
+			),
+			pages: 3,
+		},
+		{
+			name:  "Truncated",
+			data:  concat(gifHeader, gifFrame, gifFrame[:5]),
+			pages: 0,
+		},
+		{
+			name:  "NoTrailer",
+			data:  concat(gifHeader, gifFrame, gifFrame),
+			pages: 0,
+		},
+		{
+			name:  "MalformedBlock",
+			data:  concat(gifHeader, gifFrame, []byte{0x42}, gifFrame, []byte{gifTrailer}),
+			pages: 0,
+		},
+	})
+}
+
+// tiffIFD builds an IFD with image width and length entries
+func tiffIFD(nextOffset int) []byte {
+	return concat(
+		le16(2),
+		le16(tiffImageWidth), le16(tiffDtShort), le32(1), le16(2), le16(0),
+		le16(tiffImageLength), le16(tiffDtShort), le32(1), le16(2), le16(0),
+		le32(nextOffset),
+	)
+}
+
+const tiffIFDSize = 2 + 2*12 + 4
+
+func (s *PagesTestSuite) TestTiffPages() {
+	header := concat(tiffLeHeader, le32(8))
+
+	s.checkPages([]pagesTestCase{
+		{
+			name:  "SinglePage",
+			data:  concat(header, tiffIFD(0)),
+			pages: 1,
+		},
+		{
+			name: "MultiPage",
+			data: concat(
+				header,
+				tiffIFD(8+tiffIFDSize),
+				tiffIFD(8+tiffIFDSize*2),
+				tiffIFD(0),
+			),
+			pages: 3,
+		},
+		{
+			name: "Gap",
+			data: concat(
+				header,
+				tiffIFD(8+tiffIFDSize+4),
+				make([]byte, 4),
+				tiffIFD(0),
+			),
+			pages: 2,
+		},
+		{
+			name: "BackwardIFD",
+			data: concat(
+				header,
+				tiffIFD(8+tiffIFDSize),
+				tiffIFD(8),
+			),
+			pages: 0,
+		},
+		{
+			name:  "Truncated",
+			data:  concat(header, tiffIFD(8+tiffIFDSize)),
+			pages: 0,
+		},
+	})
+}
+
+func heicBox(boxType string, data ...[]byte) []byte {
+	body := concat(data...)
+	return concat(be32(8+len(body)), []byte(boxType), body)
+}
+
+func heicInfe(id int, itemType string) []byte {
+	return heicBox("infe", []byte{2, 0, 0, 0}, be16(id), be16(0), []byte(itemType), []byte{0})
+}
+
+func heicRef(refType string, from int, to ...int) []byte {
+	data := concat(be16(from), be16(len(to)))
+	for _, id := range to {
+		data = concat(data, be16(id))
+	}
+	return heicBox(refType, data)
+}
+
+func heicFile(infes [][]byte, refs ...[]byte) []byte {
+	return concat(
+		heicBox("ftyp", []byte("heic"), be32(0)),
+		heicBox("meta",
+			[]byte{0, 0, 0, 0},
+			heicBox("iinf", []byte{0, 0, 0, 0}, be16(len(infes)), concat(infes...)),
+			heicBox("iref", []byte{0, 0, 0, 0}, concat(refs...)),
+			heicBox("iprp",
+				heicBox("ipco",
+					heicBox("ispe", []byte{0, 0, 0, 0}, be32(2), be32(2)),
+				),
+			),
+		),
+	)
+}
+
+func (s *PagesTestSuite) TestHeicPages() {
+	s.checkPages([]pagesTestCase{
+		{
+			name:  "SingleImage",
+			data:  heicFile([][]byte{heicInfe(1, "hvc1")}),
+			pages: 1,
+		},
+		{
+			name: "Collection",
+			data: heicFile([][]byte{
+				heicInfe(1, "hvc1"),
+				heicInfe(2, "hvc1"),
+				heicInfe(3, "hvc1"),
+			}),
+			pages: 3,
+		},
+		{
+			name: "Thumbnail",
+			data: heicFile(
+				[][]byte{heicInfe(1, "hvc1"), heicInfe(2, "hvc1")},
+				heicRef("thmb", 2, 1),
+			),
+			pages: 1,
+		},
+		{
+			name: "Grid",
+			data: heicFile(
+				[][]byte{
+					heicInfe(1, "grid"),
+					heicInfe(2, "hvc1"),
+					heicInfe(3, "hvc1"),
+					heicInfe(4, "Exif"),
+				},
+				heicRef("dimg", 1, 2, 3),
+			),
+			pages: 1,
+		},
+		{
+			name: "OldInfeVersion",
+			data: heicFile([][]byte{
+				heicInfe(1, "hvc1"),
+				heicBox("infe", []byte{1, 0, 0, 0}, be16(2), be16(0), []byte{0, 0}),
+			}),
+			pages: 0,
+		},
+	})
+}
+
+func webpFile(chunks ...[]byte) []byte {
+	body := concat(chunks...)
+	return concat([]byte("RIFF"), le32(4+len(body)), []byte("WEBP"), body)
+}
+
+func webpChunk(fcc string, data []byte) []byte {
+	chunk := concat([]byte(fcc), le32(len(data)), data)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func webpVP8X(animated bool) []byte {
+	var flags byte
+	if animated {
+		flags = 0x02
+	}
+
+	// Flags, reserved bytes, width and height minus one
+	return webpChunk("VP8X", []byte{flags, 0, 0, 0, 1, 0, 0, 1, 0, 0})
+}
+
+func (s *PagesTestSuite) TestWebpPages() {
+	anim := webpChunk("ANIM", make([]byte, 6))
+	frame := webpChunk("ANMF", make([]byte, 17))
+
+	s.checkPages([]pagesTestCase{
+		{
+			name:  "Still",
+			data:  webpFile(webpVP8X(false), webpChunk("VP8 ", make([]byte, 10))),
+			pages: 0,
+		},
+		{
+			name:  "Animated",
+			data:  webpFile(webpVP8X(true), anim, frame, frame, frame),
+			pages: 3,
+		},
+		{
+			name:  "Truncated",
+			data:  webpFile(webpVP8X(true), anim, frame, frame)[:60],
+			pages: 0,
+		},
+	})
+}
+
+func TestPages(t *testing.T) {
+	suite.Run(t, new(PagesTestSuite))
+}
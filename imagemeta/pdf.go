@@ -0,0 +1,17 @@
+package imagemeta
+
+import (
+	"io"
+)
+
+var pdfMagick = []byte("%PDF-")
+
+// DecodePdfMeta only checks the PDF signature. Page dimensions depend on
+// the rendering DPI, so they are unknown until the page is loaded
+func DecodePdfMeta(r io.Reader) (Meta, error) {
+	return &meta{format: "pdf"}, nil
+}
+
+func init() {
+	RegisterFormat(string(pdfMagick), DecodePdfMeta)
+}
@@ -37,6 +37,48 @@ type TiffFormatError string
 
 func (e TiffFormatError) Error() string { return "invalid TIFF format: " + string(e) }
 
+// tiffCountPages follows the IFD chain to count pages. r should point to the next IFD offset
+// of the first IFD. We can't seek back, so when an IFD is located before the current position
+// or the chain is broken, the count is unknown and 0 is returned
+func tiffCountPages(r tiffReader, byteOrder binary.ByteOrder, pos int) int {
+	var tmp [4]byte
+
+	pages := 1
+
+	for {
+		if _, err := io.ReadFull(r, tmp[:4]); err != nil {
+			return 0
+		}
+		pos += 4
+
+		ifdOffset := int(byteOrder.Uint32(tmp[:4]))
+		if ifdOffset == 0 {
+			return pages
+		}
+		if ifdOffset < pos {
+			return 0
+		}
+
+		if _, err := r.Discard(ifdOffset - pos); err != nil {
+			return 0
+		}
+
+		if _, err := io.ReadFull(r, tmp[:2]); err != nil {
+			return 0
+		}
+
+		pages++
+
+		numItems := int(byteOrder.Uint16(tmp[:2]))
+
+		if _, err := r.Discard(numItems * 12); err != nil {
+			return 0
+		}
+
+		pos = ifdOffset + 2 + numItems*12
+	}
+}
+
 func DecodeTiffMeta(rr io.Reader) (Meta, error) {
 	var (
 		tmp       [12]byte
@@ -102,17 +144,18 @@ func DecodeTiffMeta(rr io.Reader) (Meta, error) {
 		} else {
 			height = value
 		}
+	}
 
-		if width > 0 && height > 0 {
-			return &meta{
-				format: "tiff",
-				width:  width,
-				height: height,
-			}, nil
-		}
+	if width == 0 || height == 0 {
+		return nil, TiffFormatError("image dimensions are not specified")
 	}
 
-	return nil, TiffFormatError("image dimensions are not specified")
+	return &meta{
+		format: "tiff",
+		width:  width,
+		height: height,
+		pages:  tiffCountPages(r, byteOrder, ifdOffset+2+numItems*12),
+	}, nil
 }
 
 func init() {
@@ -19,6 +19,7 @@ var ErrWebpInvalidFormat = errors.New("webp: invalid format")
 
 var (
 	webpFccALPH = riff.FourCC{'A', 'L', 'P', 'H'}
+	webpFccANMF = riff.FourCC{'A', 'N', 'M', 'F'}
 	webpFccVP8  = riff.FourCC{'V', 'P', '8', ' '}
 	webpFccVP8L = riff.FourCC{'V', 'P', '8', 'L'}
 	webpFccVP8X = riff.FourCC{'V', 'P', '8', 'X'}
@@ -88,10 +89,31 @@ func DecodeWebpMeta(r io.Reader) (Meta, error) {
 			widthMinusOne := uint32(buf[4]) | uint32(buf[5])<<8 | uint32(buf[6])<<16
 			heightMinusOne := uint32(buf[7]) | uint32(buf[8])<<8 | uint32(buf[9])<<16
 
+			frames := 0
+
+			// Animation flag is set, count the frames
+			if buf[0]&0x02 != 0 {
+				for {
+					chunkID, _, _, err := riffReader.Next()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						// The data is malformed or truncated, so the count is unknown
+						frames = 0
+						break
+					}
+					if chunkID == webpFccANMF {
+						frames++
+					}
+				}
+			}
+
 			return &meta{
 				format: "webp",
 				width:  int(widthMinusOne) + 1,
 				height: int(heightMinusOne) + 1,
+				pages:  frames,
 			}, nil
 
 		default:
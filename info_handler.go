@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/imgproxy/imgproxy/v2/imagemeta"
+)
+
+const infoPathPrefix = "/info"
+
+type imageInfo struct {
+	Format       string `json:"format"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Orientation  int    `json:"orientation"`
+	FramesCount  int    `json:"frames_count"`
+	HasAlpha     bool   `json:"has_alpha"`
+	Colorspace   string `json:"colorspace,omitempty"`
+	HasICC       bool   `json:"has_icc"`
+	FileSize     int    `json:"size"`
+	CacheControl string `json:"cache_control,omitempty"`
+	Expires      string `json:"expires,omitempty"`
+	Placeholder  string `json:"placeholder,omitempty"`
+}
+
+func getImageInfo(ctx context.Context) (*imageInfo, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	defer vipsCleanup()
+
+	imgdata := getImageData(ctx)
+
+	info := imageInfo{
+		Orientation:  1,
+		FramesCount:  1,
+		FileSize:     len(imgdata.Data),
+		CacheControl: getCacheControlHeader(ctx),
+		Expires:      getExpiresHeader(ctx),
+	}
+
+	meta, err := imagemeta.DecodeMeta(bytes.NewReader(imgdata.Data))
+	if err != nil {
+		return nil, err
+	}
+
+	info.Format = meta.Format()
+	info.Width, info.Height = meta.Width(), meta.Height()
+	if pages := meta.Pages(); pages > 0 {
+		info.FramesCount = pages
+	}
+
+	po := getProcessingOptions(ctx)
+
+	if err = checkPage(meta.Pages(), po.Page); err != nil {
+		return nil, err
+	}
+
+	if imgdata.Type == imageTypeICO {
+		if imgdata, err = getIcoData(imgdata); err != nil {
+			return nil, err
+		}
+	}
+
+	// We still can report dimensions if libvips can't load the image
+	if !vipsTypeSupportLoad[imgdata.Type] {
+		return &info, nil
+	}
+
+	img := new(vipsImage)
+	defer img.Clear()
+
+	if err = img.Load(imgdata.Data, imgdata.Type, 1, sourceScale(imgdata.Type, po), po.Page, 1); err != nil {
+		return nil, err
+	}
+
+	info.Width, info.Height = img.Width(), img.Height()
+	info.Orientation = int(img.Orientation())
+	info.HasAlpha = img.HasAlpha()
+	info.Colorspace = img.ColorspaceName()
+	info.HasICC = img.HasICCProfile()
+
+	if nPages, err := img.GetInt("n-pages"); err == nil && nPages > 0 {
+		info.FramesCount = nPages
+	}
+
+	if po.Placeholder != imageTypeUnknown {
+		phpo := *po
+		phpo.Format = po.Placeholder
+
+		placeholder, err := generatePlaceholder(ctx, img, imgdata, &phpo)
+		if err != nil {
+			return nil, err
+		}
+
+		info.Placeholder = string(placeholder)
+	}
+
+	return &info, nil
+}
+
+func handleInfo(reqID string, rw http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if prometheusEnabled {
+		prometheusRequestsTotal.Inc()
+		defer startPrometheusDuration(prometheusRequestDuration)()
+	}
+
+	ctx, err := parsePathWithPrefix(ctx, r, infoPathPrefix)
+	if err != nil {
+		panic(err)
+	}
+
+	processingSem <- struct{}{}
+	defer func() { <-processingSem }()
+
+	ctx, timeoutCancel := context.WithTimeout(ctx, time.Duration(conf.WriteTimeout)*time.Second)
+	defer timeoutCancel()
+
+	ctx, downloadcancel, err := downloadImage(ctx)
+	defer downloadcancel()
+	if err != nil {
+		if prometheusEnabled {
+			incrementPrometheusErrorsTotal("download")
+		}
+		panic(err)
+	}
+
+	checkTimeout(ctx)
+
+	info, err := getImageInfo(ctx)
+	if err != nil {
+		if prometheusEnabled {
+			incrementPrometheusErrorsTotal("processing")
+		}
+		panic(newError(422, err.Error(), "Can't get image info"))
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		panic(err)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(200)
+	rw.Write(data)
+
+	imageURL := getImageURL(ctx)
+
+	logResponse(reqID, r, 200, nil, &imageURL, nil)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	jpegMarkerSOS = 0xda
+	jpegMarkerCOM = 0xfe
+
+	jpegMarkerAPP1  = 0xe1
+	jpegMarkerAPP2  = 0xe2
+	jpegMarkerAPP14 = 0xee
+	jpegMarkerAPP15 = 0xef
+)
+
+var errInvalidJpeg = errors.New("Invalid JPEG data")
+
+// isJpegMetadataMarker checks if the segment contains metadata that doesn't affect
+// the decoding. JFIF (APP0), ICC profile (APP2), and Adobe (APP14) segments are kept
+func isJpegMetadataMarker(marker byte) bool {
+	return marker == jpegMarkerCOM ||
+		(marker >= jpegMarkerAPP1 && marker <= jpegMarkerAPP15 &&
+			marker != jpegMarkerAPP2 && marker != jpegMarkerAPP14)
+}
+
+// stripJpegMetadata removes EXIF, XMP, IPTC, and comment segments from the JPEG data
+// without recompression
+func stripJpegMetadata(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, errInvalidJpeg
+	}
+
+	res := make([]byte, 2, len(data))
+	copy(res, data[:2])
+
+	pos := 2
+
+	for {
+		if pos+2 > len(data) || data[pos] != 0xff {
+			return nil, errInvalidJpeg
+		}
+
+		marker := data[pos+1]
+
+		// Skip fill bytes
+		if marker == 0xff {
+			pos++
+			continue
+		}
+
+		// Scan data is not segmented, so copy the rest as is
+		if marker == jpegMarkerSOS {
+			return append(res, data[pos:]...), nil
+		}
+
+		if pos+4 > len(data) {
+			return nil, errInvalidJpeg
+		}
+
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:pos+4]))
+		if end < pos+4 || end > len(data) {
+			return nil, errInvalidJpeg
+		}
+
+		if !isJpegMetadataMarker(marker) {
+			res = append(res, data[pos:end]...)
+		}
+
+		pos = end
+	}
+}
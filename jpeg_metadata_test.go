@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type JpegMetadataTestSuite struct{ MainTestSuite }
+
+func jpegSegment(marker byte, payload string) []byte {
+	size := len(payload) + 2
+	return append([]byte{0xff, marker, byte(size >> 8), byte(size)}, payload...)
+}
+
+func (s *JpegMetadataTestSuite) TestStripJpegMetadata() {
+	var data []byte
+
+	data = append(data, 0xff, 0xd8)
+	data = append(data, jpegSegment(0xe0, "JFIF")...)
+	data = append(data, jpegSegment(0xe1, "Exif")...)
+	data = append(data, jpegSegment(0xe2, "ICC_PROFILE")...)
+	data = append(data, jpegSegment(0xed, "Photoshop")...)
+	data = append(data, jpegSegment(0xee, "Adobe")...)
+	data = append(data, jpegSegment(0xfe, "comment")...)
+	data = append(data, jpegSegment(0xdb, "quant")...)
+	data = append(data, jpegSegment(0xda, "scan")...)
+	data = append(data, 0x01, 0xff, 0x00, 0xff, 0xd9)
+
+	var expected []byte
+
+	expected = append(expected, 0xff, 0xd8)
+	expected = append(expected, jpegSegment(0xe0, "JFIF")...)
+	expected = append(expected, jpegSegment(0xe2, "ICC_PROFILE")...)
+	expected = append(expected, jpegSegment(0xee, "Adobe")...)
+	expected = append(expected, jpegSegment(0xdb, "quant")...)
+	expected = append(expected, jpegSegment(0xda, "scan")...)
+	expected = append(expected, 0x01, 0xff, 0x00, 0xff, 0xd9)
+
+	res, err := stripJpegMetadata(data)
+
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), expected, res)
+}
+
+func (s *JpegMetadataTestSuite) TestStripJpegMetadataInvalid() {
+	_, err := stripJpegMetadata([]byte{0xff, 0xd8, 0xff, 0xe1, 0x00, 0x10, 0x00})
+	require.Error(s.T(), err)
+
+	_, err = stripJpegMetadata([]byte{0x89, 'P', 'N', 'G'})
+	require.Error(s.T(), err)
+}
+
+func TestJpegMetadata(t *testing.T) {
+	suite.Run(t, new(JpegMetadataTestSuite))
+}
@@ -0,0 +1,83 @@
+// +build jxl
+
+package main
+
+/*
+#cgo pkg-config: libjxl
+#include <stdlib.h>
+#include <stdint.h>
+#include <jxl/encode.h>
+
+static int
+jxl_transcode_jpeg(void *buf, size_t len, int effort, void **out, size_t *out_len) {
+  JxlEncoder *enc = JxlEncoderCreate(NULL);
+  if (enc == NULL) return 1;
+
+  JxlEncoderFrameSettings *settings = JxlEncoderFrameSettingsCreate(enc, NULL);
+
+  int res = 1;
+  size_t size = len, offset = 0;
+  uint8_t *data = NULL, *next;
+
+  if (
+    settings == NULL ||
+    JxlEncoderStoreJPEGMetadata(enc, JXL_TRUE) != JXL_ENC_SUCCESS ||
+    JxlEncoderFrameSettingsSetOption(settings, JXL_ENC_FRAME_SETTING_EFFORT, effort) != JXL_ENC_SUCCESS ||
+    JxlEncoderAddJPEGFrame(settings, buf, len) != JXL_ENC_SUCCESS
+  ) goto cleanup;
+
+  JxlEncoderCloseInput(enc);
+
+  if ((data = malloc(size)) == NULL) goto cleanup;
+
+  for (;;) {
+    next = data + offset;
+    size_t avail = size - offset;
+
+    JxlEncoderStatus status = JxlEncoderProcessOutput(enc, &next, &avail);
+    offset = next - data;
+
+    if (status == JXL_ENC_SUCCESS) break;
+    if (status != JXL_ENC_NEED_MORE_OUTPUT) goto cleanup;
+
+    size *= 2;
+
+    uint8_t *tmp = realloc(data, size);
+    if (tmp == NULL) goto cleanup;
+    data = tmp;
+  }
+
+  *out = data;
+  *out_len = offset;
+  data = NULL;
+  res = 0;
+
+cleanup:
+  free(data);
+  JxlEncoderDestroy(enc);
+
+  return res;
+}
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+)
+
+const jxlTranscodeSupported = true
+
+// transcodeJpegToJxl losslessly recompresses JPEG data to JPEG XL
+// keeping the data needed to reconstruct the original JPEG
+func transcodeJpegToJxl(data []byte, effort int) ([]byte, error) {
+	var ptr unsafe.Pointer
+	defer func() { C.free(ptr) }()
+
+	size := C.size_t(0)
+
+	if C.jxl_transcode_jpeg(unsafe.Pointer(&data[0]), C.size_t(len(data)), C.int(effort), &ptr, &size) != 0 {
+		return nil, errors.New("Can't transcode JPEG to JPEG XL")
+	}
+
+	return C.GoBytes(ptr, C.int(size)), nil
+}
@@ -0,0 +1,11 @@
+// +build !jxl
+
+package main
+
+import "errors"
+
+const jxlTranscodeSupported = false
+
+func transcodeJpegToJxl(data []byte, effort int) ([]byte, error) {
+	return nil, errors.New("JPEG to JPEG XL transcoding is not supported. Build imgproxy with the jxl tag")
+}
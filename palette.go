@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	paletteImageMaxSize = 64
+	paletteMaxColors    = 16
+
+	// Number of bits per channel kept while building the colors histogram
+	paletteQuantBits = 5
+
+	// Size of the palette the dominant color is picked from. Picking it
+	// from a single-color palette would result in the average color
+	dominantColorPaletteSize = 5
+
+	dominantColorHeader = "X-Dominant-Color"
+)
+
+type paletteColor struct {
+	Color string  `json:"color"`
+	Share float64 `json:"share"`
+}
+
+type paletteBucket struct {
+	q          [3]int
+	r, g, b, n int
+}
+
+func paletteBucketKey(q [3]int) int {
+	return q[0]<<(2*paletteQuantBits) | q[1]<<paletteQuantBits | q[2]
+}
+
+type paletteBox struct {
+	buckets []*paletteBucket
+	n       int
+}
+
+func (box *paletteBox) widestChannel() (channel, width int) {
+	for c := 0; c < 3; c++ {
+		min, max := math.MaxInt32, math.MinInt32
+
+		for _, b := range box.buckets {
+			min = minInt(min, b.q[c])
+			max = maxInt(max, b.q[c])
+		}
+
+		if max-min > width {
+			channel, width = c, max-min
+		}
+	}
+
+	return
+}
+
+// split splits the box by the median of its widest channel
+func (box *paletteBox) split() (*paletteBox, *paletteBox) {
+	channel, _ := box.widestChannel()
+
+	sort.Slice(box.buckets, func(i, j int) bool {
+		return box.buckets[i].q[channel] < box.buckets[j].q[channel]
+	})
+
+	count := 0
+	i := 1
+	for ; i < len(box.buckets)-1; i++ {
+		count += box.buckets[i-1].n
+		if count*2 >= box.n {
+			break
+		}
+	}
+
+	left := &paletteBox{buckets: box.buckets[:i]}
+	right := &paletteBox{buckets: box.buckets[i:]}
+
+	for _, b := range left.buckets {
+		left.n += b.n
+	}
+	right.n = box.n - left.n
+
+	return left, right
+}
+
+func (box *paletteBox) color(total int) paletteColor {
+	var r, g, b int
+
+	for _, bucket := range box.buckets {
+		r += bucket.r
+		g += bucket.g
+		b += bucket.b
+	}
+
+	return paletteColor{
+		Color: fmt.Sprintf("#%02x%02x%02x", r/box.n, g/box.n, b/box.n),
+		Share: math.Round(float64(box.n)/float64(total)*10000) / 10000,
+	}
+}
+
+// quantizePalette finds up to n dominant colors of RGBA pixels using the median cut.
+// Mostly transparent pixels are ignored
+func quantizePalette(pixels []byte, n int) []paletteColor {
+	histogram := make(map[int]*paletteBucket)
+	total := 0
+
+	shift := uint(8 - paletteQuantBits)
+
+	for i := 0; i+3 < len(pixels); i += 4 {
+		if pixels[i+3] < 128 {
+			continue
+		}
+
+		r, g, b := int(pixels[i]), int(pixels[i+1]), int(pixels[i+2])
+		q := [3]int{r >> shift, g >> shift, b >> shift}
+		key := paletteBucketKey(q)
+
+		bucket, ok := histogram[key]
+		if !ok {
+			bucket = &paletteBucket{q: q}
+			histogram[key] = bucket
+		}
+
+		bucket.r += r
+		bucket.g += g
+		bucket.b += b
+		bucket.n++
+
+		total++
+	}
+
+	if total == 0 {
+		return []paletteColor{}
+	}
+
+	box := &paletteBox{buckets: make([]*paletteBucket, 0, len(histogram)), n: total}
+	for _, bucket := range histogram {
+		box.buckets = append(box.buckets, bucket)
+	}
+
+	// Make the result independent from the map iteration order
+	sort.Slice(box.buckets, func(i, j int) bool {
+		return paletteBucketKey(box.buckets[i].q) < paletteBucketKey(box.buckets[j].q)
+	})
+
+	boxes := []*paletteBox{box}
+
+	for len(boxes) < n {
+		// Split the most populated box that can be split
+		idx := -1
+		for i, b := range boxes {
+			if len(b.buckets) > 1 && (idx < 0 || b.n > boxes[idx].n) {
+				idx = i
+			}
+		}
+
+		if idx < 0 {
+			break
+		}
+
+		left, right := boxes[idx].split()
+		boxes[idx] = left
+		boxes = append(boxes, right)
+	}
+
+	sort.SliceStable(boxes, func(i, j int) bool {
+		return boxes[i].n > boxes[j].n
+	})
+
+	colors := make([]paletteColor, len(boxes))
+	for i, b := range boxes {
+		colors[i] = b.color(total)
+	}
+
+	return colors
+}
+
+// calcPalette calculates the palette of a downscaled copy of the image.
+// Only the first frame of an animated image is taken into account
+func calcPalette(img *vipsImage, n int) ([]paletteColor, error) {
+	tmp := new(vipsImage)
+	defer tmp.Clear()
+
+	height := img.Height()
+
+	if img.IsAnimated() {
+		frameHeight, err := img.GetInt("page-height")
+		if err != nil {
+			return nil, err
+		}
+
+		height = minInt(frameHeight, height)
+	}
+
+	if err := img.Extract(tmp, 0, 0, img.Width(), height); err != nil {
+		return nil, err
+	}
+
+	if imgMax := maxInt(tmp.Width(), tmp.Height()); imgMax > paletteImageMaxSize {
+		scale := float64(paletteImageMaxSize) / float64(imgMax)
+		if err := tmp.Resize(scale, scale, tmp.HasAlpha()); err != nil {
+			return nil, err
+		}
+	}
+
+	pixels, err := tmp.RGBAPixels()
+	if err != nil {
+		return nil, err
+	}
+
+	return quantizePalette(pixels, n), nil
+}
+
+// calcDominantColor returns the hex code of the image dominant color
+// or an empty string if the image is fully transparent
+func calcDominantColor(img *vipsImage) (string, error) {
+	colors, err := calcPalette(img, dominantColorPaletteSize)
+	if err != nil || len(colors) == 0 {
+		return "", err
+	}
+
+	return colors[0].Color, nil
+}
+
+// generatePalette processes the image and returns its palette as JSON
+func generatePalette(ctx context.Context, img *vipsImage, imgdata *imageData, po *processingOptions) ([]byte, error) {
+	if err := transformImage(ctx, img, imgdata.Data, po, imgdata.Type); err != nil {
+		return nil, err
+	}
+
+	colors, err := calcPalette(img, po.Palette)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		Colors []paletteColor `json:"colors"`
+	}{colors})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type PaletteTestSuite struct{ MainTestSuite }
+
+func (s *PaletteTestSuite) TestQuantizePalette() {
+	pixels := append(
+		bytes.Repeat([]byte{255, 0, 0, 255}, 75),
+		bytes.Repeat([]byte{0, 0, 255, 255}, 25)...,
+	)
+
+	colors := quantizePalette(pixels, 4)
+
+	require.Len(s.T(), colors, 2)
+	assert.Equal(s.T(), paletteColor{Color: "#ff0000", Share: 0.75}, colors[0])
+	assert.Equal(s.T(), paletteColor{Color: "#0000ff", Share: 0.25}, colors[1])
+}
+
+func (s *PaletteTestSuite) TestQuantizePaletteLimit() {
+	pixels := append(
+		bytes.Repeat([]byte{255, 0, 0, 255}, 50),
+		bytes.Repeat([]byte{0, 255, 0, 255}, 30)...,
+	)
+	pixels = append(pixels, bytes.Repeat([]byte{0, 0, 255, 255}, 20)...)
+
+	colors := quantizePalette(pixels, 1)
+
+	require.Len(s.T(), colors, 1)
+	assert.Equal(s.T(), 1.0, colors[0].Share)
+}
+
+func (s *PaletteTestSuite) TestQuantizePaletteTransparent() {
+	pixels := append(
+		bytes.Repeat([]byte{255, 0, 0, 0}, 90),
+		bytes.Repeat([]byte{0, 255, 0, 255}, 10)...,
+	)
+
+	colors := quantizePalette(pixels, 4)
+
+	require.Len(s.T(), colors, 1)
+	assert.Equal(s.T(), paletteColor{Color: "#00ff00", Share: 1}, colors[0])
+
+	assert.Empty(s.T(), quantizePalette(bytes.Repeat([]byte{255, 0, 0, 0}, 10), 4))
+}
+
+func TestPalette(t *testing.T) {
+	suite.Run(t, new(PaletteTestSuite))
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"math"
+	"strings"
+)
+
+const (
+	blurhashMaxSize  = 32
+	thumbhashMaxSize = 100
+
+	blurhashChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+)
+
+func placeholderMaxSize(imgtype imageType) int {
+	if imgtype == imageTypeBLURHASH {
+		return blurhashMaxSize
+	}
+
+	return thumbhashMaxSize
+}
+
+// preparePlaceholderOptions modifies processing options so the image is processed
+// right at the placeholder size. This way we can benefit from scale-on-load
+func preparePlaceholderOptions(po *processingOptions) {
+	maxSize := placeholderMaxSize(po.Format)
+
+	width := scaleInt(po.Width, po.Dpr)
+	height := scaleInt(po.Height, po.Dpr)
+
+	if width == 0 && height == 0 {
+		po.Width, po.Height = maxSize, maxSize
+		po.ResizingType = resizeFit
+	} else if scale := float64(maxSize) / float64(maxInt(width, height)); scale < 1 {
+		po.Width = scaleInt(width, scale)
+		po.Height = scaleInt(height, scale)
+	} else {
+		po.Width, po.Height = width, height
+	}
+
+	po.Dpr = 1
+	po.MinWidth, po.MinHeight = 0, 0
+	po.Watermarks = nil
+}
+
+// generatePlaceholder processes the image and encodes it to the placeholder hash
+func generatePlaceholder(ctx context.Context, img *vipsImage, imgdata *imageData, po *processingOptions) ([]byte, error) {
+	preparePlaceholderOptions(po)
+
+	if err := transformImage(ctx, img, imgdata.Data, po, imgdata.Type); err != nil {
+		return nil, err
+	}
+
+	// The resulting image still can be larger than needed because of extend, padding, etc
+	maxSize := placeholderMaxSize(po.Format)
+	if imgMax := maxInt(img.Width(), img.Height()); imgMax > maxSize {
+		scale := float64(maxSize) / float64(imgMax)
+		if err := img.Resize(scale, scale, img.HasAlpha()); err != nil {
+			return nil, err
+		}
+	}
+
+	pixels, err := img.RGBAPixels()
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := img.Width(), img.Height()
+
+	if po.Format == imageTypeBLURHASH {
+		xComp, yComp := 4, 3
+		if height > width {
+			xComp, yComp = 3, 4
+		}
+
+		return []byte(encodeBlurhash(xComp, yComp, width, height, pixels)), nil
+	}
+
+	hash := encodeThumbhash(width, height, pixels)
+
+	return []byte(base64.StdEncoding.EncodeToString(hash)), nil
+}
+
+func srgbToLinear(v uint8) float64 {
+	c := float64(v) / 255
+
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSrgb(v float64) int {
+	c := math.Max(0, math.Min(1, v))
+
+	if c <= 0.0031308 {
+		return int(c*12.92*255 + 0.5)
+	}
+
+	return int((1.055*math.Pow(c, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+func encodeBase83(b *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		b.WriteByte(blurhashChars[digit])
+	}
+}
+
+// encodeBlurhash encodes RGBA pixels to BlurHash.
+// See https://github.com/woltapp/blurhash/blob/master/Algorithm.md
+func encodeBlurhash(xComp, yComp, width, height int, pixels []byte) string {
+	factors := make([][3]float64, 0, xComp*yComp)
+
+	for y := 0; y < yComp; y++ {
+		for x := 0; x < xComp; x++ {
+			normalisation := 2.0
+			if x == 0 && y == 0 {
+				normalisation = 1
+			}
+
+			var r, g, b float64
+
+			for j := 0; j < height; j++ {
+				basisY := math.Cos(math.Pi * float64(y) * float64(j) / float64(height))
+
+				for i := 0; i < width; i++ {
+					basis := math.Cos(math.Pi*float64(x)*float64(i)/float64(width)) * basisY
+					offset := (j*width + i) * 4
+
+					r += basis * srgbToLinear(pixels[offset])
+					g += basis * srgbToLinear(pixels[offset+1])
+					b += basis * srgbToLinear(pixels[offset+2])
+				}
+			}
+
+			scale := normalisation / float64(width*height)
+
+			factors = append(factors, [3]float64{r * scale, g * scale, b * scale})
+		}
+	}
+
+	dc, ac := factors[0], factors[1:]
+
+	var hash strings.Builder
+
+	encodeBase83(&hash, (xComp-1)+(yComp-1)*9, 1)
+
+	maxValue := 1.0
+
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantisedMax+1) / 166
+
+		encodeBase83(&hash, quantisedMax, 1)
+	} else {
+		encodeBase83(&hash, 0, 1)
+	}
+
+	encodeBase83(&hash, (linearToSrgb(dc[0])<<16)+(linearToSrgb(dc[1])<<8)+linearToSrgb(dc[2]), 4)
+
+	quantise := func(v float64) int {
+		return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+	}
+
+	for _, f := range ac {
+		encodeBase83(&hash, quantise(f[0])*19*19+quantise(f[1])*19+quantise(f[2]), 2)
+	}
+
+	return hash.String()
+}
+
+// jsRound rounds the number the same way as JavaScript's Math.round does
+func jsRound(v float64) int {
+	return int(math.Floor(v + 0.5))
+}
+
+// encodeThumbhash encodes RGBA pixels to ThumbHash. The image should be no larger than 100x100.
+// See https://github.com/evanw/thumbhash
+func encodeThumbhash(width, height int, pixels []byte) []byte {
+	var avgR, avgG, avgB, avgA float64
+
+	for i := 0; i < width*height; i++ {
+		alpha := float64(pixels[i*4+3]) / 255
+
+		avgR += alpha / 255 * float64(pixels[i*4])
+		avgG += alpha / 255 * float64(pixels[i*4+1])
+		avgB += alpha / 255 * float64(pixels[i*4+2])
+		avgA += alpha
+	}
+
+	if avgA > 0 {
+		avgR /= avgA
+		avgG /= avgA
+		avgB /= avgA
+	}
+
+	hasAlpha := avgA < float64(width*height)
+
+	// Use fewer luminance bits if there's alpha
+	lLimit := 7.0
+	if hasAlpha {
+		lLimit = 5
+	}
+
+	maxSide := float64(maxInt(width, height))
+	lx := maxInt(1, jsRound(lLimit*float64(width)/maxSide))
+	ly := maxInt(1, jsRound(lLimit*float64(height)/maxSide))
+
+	l := make([]float64, width*height) // luminance
+	p := make([]float64, width*height) // yellow - blue
+	q := make([]float64, width*height) // red - green
+	a := make([]float64, width*height) // alpha
+
+	// Convert the image from RGBA to LPQA (composite atop the average color)
+	for i := 0; i < width*height; i++ {
+		alpha := float64(pixels[i*4+3]) / 255
+
+		r := avgR*(1-alpha) + alpha/255*float64(pixels[i*4])
+		g := avgG*(1-alpha) + alpha/255*float64(pixels[i*4+1])
+		b := avgB*(1-alpha) + alpha/255*float64(pixels[i*4+2])
+
+		l[i] = (r + g + b) / 3
+		p[i] = (r+g)/2 - b
+		q[i] = r - g
+		a[i] = alpha
+	}
+
+	// Encode using the DCT into DC (constant) and normalized AC (varying) terms
+	encodeChannel := func(channel []float64, nx, ny int) (dc float64, ac []float64, scale float64) {
+		fx := make([]float64, width)
+
+		for cy := 0; cy < ny; cy++ {
+			for cx := 0; cx*ny < nx*(ny-cy); cx++ {
+				f := 0.0
+
+				for x := 0; x < width; x++ {
+					fx[x] = math.Cos(math.Pi / float64(width) * float64(cx) * (float64(x) + 0.5))
+				}
+
+				for y := 0; y < height; y++ {
+					fy := math.Cos(math.Pi / float64(height) * float64(cy) * (float64(y) + 0.5))
+
+					for x := 0; x < width; x++ {
+						f += channel[x+y*width] * fx[x] * fy
+					}
+				}
+
+				f /= float64(width * height)
+
+				if cx > 0 || cy > 0 {
+					ac = append(ac, f)
+					scale = math.Max(scale, math.Abs(f))
+				} else {
+					dc = f
+				}
+			}
+		}
+
+		if scale > 0 {
+			for i := range ac {
+				ac[i] = 0.5 + 0.5/scale*ac[i]
+			}
+		}
+
+		return
+	}
+
+	lDC, lAC, lScale := encodeChannel(l, maxInt(3, lx), maxInt(3, ly))
+	pDC, pAC, pScale := encodeChannel(p, 3, 3)
+	qDC, qAC, qScale := encodeChannel(q, 3, 3)
+
+	var (
+		aDC, aScale float64
+		aAC         []float64
+	)
+
+	if hasAlpha {
+		aDC, aAC, aScale = encodeChannel(a, 5, 5)
+	}
+
+	isLandscape := width > height
+
+	header24 := jsRound(63*lDC) |
+		(jsRound(31.5+31.5*pDC) << 6) |
+		(jsRound(31.5+31.5*qDC) << 12) |
+		(jsRound(31*lScale) << 18)
+	if hasAlpha {
+		header24 |= 1 << 23
+	}
+
+	header16 := jsRound(63*pScale)<<3 | jsRound(63*qScale)<<9
+	if isLandscape {
+		header16 |= ly | 1<<15
+	} else {
+		header16 |= lx
+	}
+
+	hash := []byte{
+		byte(header24 & 255), byte((header24 >> 8) & 255), byte(header24 >> 16),
+		byte(header16 & 255), byte(header16 >> 8),
+	}
+
+	channels := [][]float64{lAC, pAC, qAC}
+
+	if hasAlpha {
+		hash = append(hash, byte(jsRound(15*aDC)|jsRound(15*aScale)<<4))
+		channels = append(channels, aAC)
+	}
+
+	// Write the varying factors
+	acIndex := 0
+	for _, ac := range channels {
+		for _, f := range ac {
+			if acIndex&1 == 0 {
+				hash = append(hash, 0)
+			}
+			hash[len(hash)-1] |= byte(jsRound(15*f) << uint((acIndex&1)<<2))
+			acIndex++
+		}
+	}
+
+	return hash
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type PlaceholderTestSuite struct{ MainTestSuite }
+
+func (s *PlaceholderTestSuite) TestEncodeBlurhashSolidColor() {
+	pixels := bytes.Repeat([]byte{255, 255, 255, 255}, 16*16)
+
+	hash := encodeBlurhash(4, 3, 16, 16, pixels)
+
+	assert.Equal(s.T(), "LKTSUA~qfQ~q~qoffQoffQfQfQfQ", hash)
+}
+
+func (s *PlaceholderTestSuite) TestEncodeThumbhashLength() {
+	pixels := bytes.Repeat([]byte{10, 20, 30, 255}, 32*32)
+
+	hash := encodeThumbhash(32, 32, pixels)
+
+	// 5 header bytes + 37 AC factors packed by two into a byte
+	assert.Len(s.T(), hash, 24)
+	// No alpha flag
+	assert.Zero(s.T(), hash[2]&0x80)
+}
+
+func (s *PlaceholderTestSuite) TestEncodeThumbhashAlpha() {
+	pixels := bytes.Repeat([]byte{10, 20, 30, 128}, 32*32)
+
+	hash := encodeThumbhash(32, 32, pixels)
+
+	assert.NotZero(s.T(), hash[2]&0x80)
+}
+
+func TestPlaceholder(t *testing.T) {
+	suite.Run(t, new(PlaceholderTestSuite))
+}
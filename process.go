@@ -15,10 +15,19 @@ const (
 
 	// https://chromium.googlesource.com/webm/libwebp/+/refs/heads/master/src/webp/encode.h#529
 	webpMaxDimension = 16383.0
+
+	// Browsers treat smaller frame delays as 100ms
+	minFrameDelay = 20
 )
 
 var errConvertingNonSvgToSvg = newError(422, "Converting non-SVG images to SVG is not supported", "Converting non-SVG images to SVG is not supported")
 
+var errFramesOutOfRange = newError(422, "Frames start is out of range", "Invalid frames")
+
+var errFramesWithPage = newError(422, "Frames can't be used along with page", "Invalid frames")
+
+var errPageOutOfRange = newError(422, "Page is out of range", "Invalid page")
+
 func imageTypeLoadSupport(imgtype imageType) bool {
 	return imgtype == imageTypeSVG ||
 		imgtype == imageTypeICO ||
@@ -26,43 +35,238 @@ func imageTypeLoadSupport(imgtype imageType) bool {
 }
 
 func imageTypeSaveSupport(imgtype imageType) bool {
-	return imgtype == imageTypeSVG || vipsTypeSupportSave[imgtype]
+	return imgtype == imageTypeSVG || imgtype.IsPlaceholder() || vipsTypeSupportSave[imgtype]
 }
 
 func imageTypeGoodForWeb(imgtype imageType) bool {
 	return imgtype != imageTypeTIFF &&
-		imgtype != imageTypeBMP
+		imgtype != imageTypeBMP &&
+		imgtype != imageTypeJXL
+}
+
+// checkPage checks if the source image has the requested page.
+// When the pages count is unknown, libvips checks the page on load
+func checkPage(pages, page int) error {
+	if pages > 0 && page >= pages {
+		return errPageOutOfRange
+	}
+
+	return nil
 }
 
-func extractMeta(img *vipsImage) (int, int, int, bool) {
+// canTranscodeToJxl checks if the JPEG image can be losslessly recompressed to JPEG XL.
+// This is possible only when the processing options don't change pixels
+func canTranscodeToJxl(po *processingOptions, img *vipsImage) bool {
+	if !jxlTranscodeSupported {
+		return false
+	}
+
+	if po.AutoRotate && img.Orientation() > 1 {
+		return false
+	}
+
+	for _, e := range po.Diff() {
+		switch e.Name {
+		case "Format", "StripMetadata", "AutoRotate", "CacheBuster", "Expires",
+			"PreferWebP", "EnforceWebP", "PreferAvif", "EnforceAvif", "PreferJxl", "EnforceJxl",
+			"Filename", "UsedPresets", "DominantColor", "SourceURLEncrypted":
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// transcodeJpegToJxlWithMeta losslessly recompresses JPEG to JPEG XL
+// stripping the metadata first if needed
+func transcodeJpegToJxlWithMeta(data []byte, stripMeta bool) ([]byte, error) {
+	if stripMeta {
+		var err error
+		if data, err = stripJpegMetadata(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return transcodeJpegToJxl(data, conf.JxlEffort)
+}
+
+func extractMeta(img *vipsImage, baseAngle int, useOrientation bool) (int, int, int, bool) {
 	width := img.Width()
 	height := img.Height()
 
 	angle := vipsAngleD0
 	flip := false
 
-	orientation := img.Orientation()
+	if useOrientation {
+		orientation := img.Orientation()
+
+		if orientation == 3 || orientation == 4 {
+			angle = vipsAngleD180
+		}
+		if orientation == 5 || orientation == 6 {
+			angle = vipsAngleD90
+		}
+		if orientation == 7 || orientation == 8 {
+			angle = vipsAngleD270
+		}
+		if orientation == 2 || orientation == 4 || orientation == 5 || orientation == 7 {
+			flip = true
+		}
+	}
 
-	if orientation >= 5 && orientation <= 8 {
+	if (angle+baseAngle/90)%2 == 1 {
 		width, height = height, width
 	}
-	if orientation == 3 || orientation == 4 {
-		angle = vipsAngleD180
+
+	return width, height, angle, flip
+}
+
+// relativeToAbsoluteSize converts the size to pixels. Values between 0 and 1
+// are treated as fractions of the base size
+func relativeToAbsoluteSize(size float64, base int) int {
+	if size > 0 && size < 1 {
+		return maxInt(roundToInt(size*float64(base)), 1)
 	}
-	if orientation == 5 || orientation == 6 {
-		angle = vipsAngleD90
+
+	return int(size)
+}
+
+// relativeToAbsoluteOffset converts the gravity offset to pixels. Values between -1 and 1
+// are treated as fractions of the base size
+func relativeToAbsoluteOffset(offset float64, base int) float64 {
+	if offset > -1 && offset < 1 {
+		return offset * float64(base)
 	}
-	if orientation == 7 || orientation == 8 {
-		angle = vipsAngleD270
+
+	return offset
+}
+
+// calcPadding converts the padding to pixels. Values between 0 and 1 are treated
+// as fractions of the image size while absolute values follow dpr
+func calcPadding(padding float64, base int, dpr float64) int {
+	if padding < 1 {
+		return roundToInt(padding * float64(base))
 	}
-	if orientation == 2 || orientation == 4 || orientation == 5 || orientation == 7 {
-		flip = true
+
+	return roundToInt(padding * dpr)
+}
+
+// calcRatioCropSize calculates the size of the largest area of the given
+// aspect ratio that fits the width x height rectangle
+func calcRatioCropSize(width, height int, ratio float64) (int, int) {
+	if float64(width)/float64(height) > ratio {
+		return maxInt(roundToInt(float64(height)*ratio), 1), height
 	}
 
-	return width, height, angle, flip
+	return width, maxInt(roundToInt(float64(width)/ratio), 1)
+}
+
+// splitRotationAngle splits the angle into the part that is a multiple of 90
+// and the rest that needs rotation by an arbitrary angle
+func splitRotationAngle(angle float64) (int, float64) {
+	rightAngle := int(angle/90) * 90
+	return rightAngle, angle - float64(rightAngle)
+}
+
+// calcInscribedRect calculates the size of the largest axis-aligned rectangle
+// inscribed into the width x height rectangle rotated by the angle
+func calcInscribedRect(width, height int, angle float64) (int, int) {
+	if width <= 0 || height <= 0 {
+		return 0, 0
+	}
+
+	w, h := float64(width), float64(height)
+
+	longSide, shortSide := w, h
+	if h > w {
+		longSide, shortSide = h, w
+	}
+
+	rad := angle * math.Pi / 180
+	sin, cos := math.Abs(math.Sin(rad)), math.Abs(math.Cos(rad))
+
+	var rw, rh float64
+
+	if shortSide <= 2*sin*cos*longSide || math.Abs(sin-cos) < 1e-10 {
+		// Half constrained case: two crop corners touch the longer side
+		x := 0.5 * shortSide
+		if w >= h {
+			rw, rh = x/sin, x/cos
+		} else {
+			rw, rh = x/cos, x/sin
+		}
+	} else {
+		// Fully constrained case: crop touches all 4 sides
+		cos2 := cos*cos - sin*sin
+		rw, rh = (w*cos-h*sin)/cos2, (h*cos-w*sin)/cos2
+	}
+
+	return int(math.Floor(rw)), int(math.Floor(rh))
+}
+
+func rotateImage(img *vipsImage, angle float64, po *processingOptions) error {
+	width, height := img.Width(), img.Height()
+
+	transparentBg := po.Format.SupportsAlpha() && !po.Flatten
+
+	if err := img.RotateAngle(angle, po.Background, transparentBg); err != nil {
+		return err
+	}
+
+	if !po.Rotate.Crop {
+		return fitRotatedImage(img, width, height, po)
+	}
+
+	cropWidth, cropHeight := calcInscribedRect(width, height, angle)
+
+	return cropImage(img, cropWidth, cropHeight, &gravityOptions{Type: gravityCenter})
 }
 
-func calcScale(width, height int, po *processingOptions, imgtype imageType) float64 {
+// fitRotatedImage shrinks the rotated image so it fits the requested size
+// when the image was resized to fit. Filled images are cropped later
+func fitRotatedImage(img *vipsImage, width, height int, po *processingOptions) error {
+	if po.Width == 0 && po.Height == 0 {
+		return nil
+	}
+
+	if po.Width > 0 && po.Height > 0 && calcResizingType(width, height, po) != resizeFit {
+		return nil
+	}
+
+	scale := 1.0
+
+	if po.Width > 0 {
+		scale = math.Min(scale, float64(scaleInt(po.Width, po.Dpr))/float64(img.Width()))
+	}
+	if po.Height > 0 {
+		scale = math.Min(scale, float64(scaleInt(po.Height, po.Dpr))/float64(img.Height()))
+	}
+
+	if scale >= 1 {
+		return nil
+	}
+
+	return img.Resize(scale, scale, img.HasAlpha())
+}
+
+func calcResizingType(width, height int, po *processingOptions) resizeType {
+	if po.ResizingType != resizeAuto {
+		return po.ResizingType
+	}
+
+	srcD := width - height
+	dstD := po.Width - po.Height
+
+	if (srcD >= 0 && dstD >= 0) || (srcD < 0 && dstD < 0) {
+		return resizeFill
+	}
+
+	return resizeFit
+}
+
+func calcScale(width, height int, po *processingOptions, imgtype imageType) (float64, float64) {
 	var shrink float64
 
 	srcW, srcH := float64(width), float64(height)
@@ -82,18 +286,7 @@ func calcScale(width, height int, po *processingOptions, imgtype imageType) floa
 		wshrink := srcW / dstW
 		hshrink := srcH / dstH
 
-		rt := po.ResizingType
-
-		if rt == resizeAuto {
-			srcD := width - height
-			dstD := po.Width - po.Height
-
-			if (srcD >= 0 && dstD >= 0) || (srcD < 0 && dstD < 0) {
-				rt = resizeFill
-			} else {
-				rt = resizeFit
-			}
-		}
+		rt := calcResizingType(width, height, po)
 
 		switch {
 		case po.Width == 0:
@@ -107,25 +300,61 @@ func calcScale(width, height int, po *processingOptions, imgtype imageType) floa
 		}
 	}
 
-	if !po.Enlarge && shrink < 1 && imgtype != imageTypeSVG {
-		shrink = 1
+	wshrink := shrink / po.ZoomWidth
+	hshrink := shrink / po.ZoomHeight
+
+	if !po.Enlarge && imgtype != imageTypeSVG {
+		if wshrink < 1 {
+			hshrink /= wshrink
+			wshrink = 1
+		}
+		if hshrink < 1 {
+			wshrink /= hshrink
+			hshrink = 1
+		}
+	}
+
+	wshrink /= po.Dpr
+	hshrink /= po.Dpr
+
+	// Min dimensions can enlarge the image regardless of the enlarge option
+	if po.MinWidth > 0 {
+		if minShrink := srcW / (float64(po.MinWidth) * po.Dpr); minShrink < wshrink {
+			hshrink /= wshrink / minShrink
+			wshrink = minShrink
+		}
+	}
+
+	if po.MinHeight > 0 {
+		if minShrink := srcH / (float64(po.MinHeight) * po.Dpr); minShrink < hshrink {
+			wshrink /= hshrink / minShrink
+			hshrink = minShrink
+		}
 	}
 
-	shrink /= po.Dpr
+	if wshrink > srcW {
+		wshrink = srcW
+	}
 
-	if shrink > srcW {
-		shrink = srcW
+	if hshrink > srcH {
+		hshrink = srcH
 	}
 
-	if shrink > srcH {
-		shrink = srcH
+	return 1.0 / wshrink, 1.0 / hshrink
+}
+
+// sourceScale returns the scale the source image is loaded at before any processing.
+// PDF pages are rendered at the requested DPI, and 72 DPI is the PDF native resolution
+func sourceScale(imgtype imageType, po *processingOptions) float64 {
+	if imgtype == imageTypePDF {
+		return po.Dpi / 72
 	}
 
-	return 1.0 / shrink
+	return 1
 }
 
 func canScaleOnLoad(imgtype imageType, scale float64) bool {
-	if imgtype == imageTypeSVG {
+	if imgtype == imageTypeSVG || imgtype == imageTypePDF {
 		return true
 	}
 
@@ -138,8 +367,11 @@ func canScaleOnLoad(imgtype imageType, scale float64) bool {
 
 func canFitToBytes(imgtype imageType) bool {
 	switch imgtype {
-	case imageTypeJPEG, imageTypeWEBP, imageTypeHEIC, imageTypeTIFF:
+	case imageTypeJPEG, imageTypeWEBP, imageTypeAVIF, imageTypeHEIC, imageTypeTIFF:
 		return true
+	case imageTypeJXL:
+		// Quality doesn't affect lossless JPEG XL
+		return !conf.JxlLossless
 	default:
 		return false
 	}
@@ -237,22 +469,36 @@ func cropImage(img *vipsImage, cropWidth, cropHeight int, gravity *gravityOption
 }
 
 func prepareWatermark(wm *vipsImage, wmData *imageData, opts *watermarkOptions, imgWidth, imgHeight int) error {
-	if err := wm.Load(wmData.Data, wmData.Type, 1, 1.0, 1); err != nil {
-		return err
+	var (
+		data    []byte
+		imgtype imageType
+	)
+
+	if len(opts.Text.Text) > 0 {
+		font := fmt.Sprintf("%s %d", opts.Text.Font, opts.Text.Size)
+		if err := wm.Text(opts.Text.Text, font, opts.Text.Color); err != nil {
+			return err
+		}
+		imgtype = imageTypePNG
+	} else {
+		if err := wm.Load(wmData.Data, wmData.Type, 1, 1.0, 0, 1); err != nil {
+			return err
+		}
+		data, imgtype = wmData.Data, wmData.Type
 	}
 
 	po := newProcessingOptions()
 	po.ResizingType = resizeFit
 	po.Dpr = 1
 	po.Enlarge = true
-	po.Format = wmData.Type
+	po.Format = imgtype
 
 	if opts.Scale > 0 {
 		po.Width = maxInt(scaleInt(imgWidth, opts.Scale), 1)
 		po.Height = maxInt(scaleInt(imgHeight, opts.Scale), 1)
 	}
 
-	if err := transformImage(context.Background(), wm, wmData.Data, po, wmData.Type); err != nil {
+	if err := transformImage(context.Background(), wm, data, po, imgtype); err != nil {
 		return err
 	}
 
@@ -299,12 +545,167 @@ func applyWatermark(img *vipsImage, wmData *imageData, opts *watermarkOptions, f
 	return img.ApplyWatermark(wm, opacity)
 }
 
+func applyWatermarks(ctx context.Context, img *vipsImage, po *processingOptions, framesCount int) error {
+	for i := range po.Watermarks {
+		opts := &po.Watermarks[i]
+
+		if !opts.Enabled {
+			continue
+		}
+
+		wmData := getWatermark(ctx, opts)
+		if wmData == nil && len(opts.Text.Text) == 0 {
+			continue
+		}
+
+		if err := applyWatermark(img, wmData, opts, framesCount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func copyMemoryAndCheckTimeout(ctx context.Context, img *vipsImage) error {
 	err := img.CopyMemory()
 	checkTimeout(ctx)
 	return err
 }
 
+// Rec. 709 luma coefficients
+const (
+	lumaR = 0.2126
+	lumaG = 0.7152
+	lumaB = 0.0722
+)
+
+var (
+	grayscaleMatrix = [9]float64{
+		lumaR, lumaG, lumaB,
+		lumaR, lumaG, lumaB,
+		lumaR, lumaG, lumaB,
+	}
+	sepiaMatrix = [9]float64{
+		0.393, 0.769, 0.189,
+		0.349, 0.686, 0.168,
+		0.272, 0.534, 0.131,
+	}
+)
+
+// tintMatrix mixes the original image with its luminance colorized by c
+func tintMatrix(c rgbColor, strength float64) [9]float64 {
+	var m [9]float64
+
+	for i, v := range [3]uint8{c.R, c.G, c.B} {
+		k := strength * float64(v) / 255
+		m[i*3] = k * lumaR
+		m[i*3+1] = k * lumaG
+		m[i*3+2] = k * lumaB
+		m[i*3+i] += 1 - strength
+	}
+
+	return m
+}
+
+func applyColorEffects(img *vipsImage, po *processingOptions) error {
+	if po.Grayscale {
+		if err := img.Recomb(grayscaleMatrix); err != nil {
+			return err
+		}
+	}
+
+	if po.Sepia {
+		if err := img.Recomb(sepiaMatrix); err != nil {
+			return err
+		}
+	}
+
+	if po.Duotone.Enabled {
+		if err := img.Duotone(po.Duotone.Shadow, po.Duotone.Highlight); err != nil {
+			return err
+		}
+	}
+
+	if po.Tint.Enabled && po.Tint.Strength > 0 {
+		if err := img.Recomb(tintMatrix(po.Tint.Color, po.Tint.Strength)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func blurAreas(img *vipsImage, areas []blurAreaOptions, wscale, hscale float64) error {
+	imgWidth, imgHeight := img.Width(), img.Height()
+
+	for _, area := range areas {
+		left := minInt(scaleInt(area.X, wscale), imgWidth)
+		top := minInt(scaleInt(area.Y, hscale), imgHeight)
+		right := minInt(scaleInt(area.X+area.Width, wscale), imgWidth)
+		bottom := minInt(scaleInt(area.Y+area.Height, hscale), imgHeight)
+
+		if right <= left || bottom <= top {
+			continue
+		}
+
+		if err := img.BlurArea(left, top, right-left, bottom-top, area.Sigma); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyMask(img *vipsImage, po *processingOptions, transparentBg bool) error {
+	halfWidth := float64(img.Width()) / 2
+	halfHeight := float64(img.Height()) / 2
+	maxRadius := math.Min(halfWidth, halfHeight)
+
+	var err error
+
+	if po.Mask == maskCircle {
+		err = img.RoundedMask(maxRadius, maxRadius, maxRadius)
+	} else {
+		radius := math.Min(float64(po.CornerRadius)*po.Dpr, maxRadius)
+		err = img.RoundedMask(halfWidth, halfHeight, radius)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !transparentBg {
+		return img.Flatten(po.Background)
+	}
+
+	return nil
+}
+
+func applyShadow(img *vipsImage, po *processingOptions, transparentBg bool) error {
+	sigma := po.Shadow.Blur * po.Dpr
+	offX := scaleInt(po.Shadow.OffsetX, po.Dpr)
+	offY := scaleInt(po.Shadow.OffsetY, po.Dpr)
+
+	// Gaussian blur spreads the shadow by ~3 sigmas
+	spread := int(math.Ceil(sigma * 3))
+
+	width := img.Width() + spread*2 + absInt(offX)
+	height := img.Height() + spread*2 + absInt(offY)
+
+	imgX := spread + maxInt(-offX, 0)
+	imgY := spread + maxInt(-offY, 0)
+
+	if err := img.Shadow(width, height, imgX, imgY, imgX+offX, imgY+offY, sigma, po.Shadow.Color, po.Shadow.Opacity); err != nil {
+		return err
+	}
+
+	if !transparentBg {
+		return img.Flatten(po.Background)
+	}
+
+	return nil
+}
+
 func transformImage(ctx context.Context, img *vipsImage, data []byte, po *processingOptions, imgtype imageType) error {
 	var (
 		err     error
@@ -321,8 +722,19 @@ func transformImage(ctx context.Context, img *vipsImage, data []byte, po *proces
 		trimmed = true
 	}
 
-	srcWidth, srcHeight, angle, flip := extractMeta(img)
-	cropWidth, cropHeight := po.Crop.Width, po.Crop.Height
+	rightAngle, extraAngle := splitRotationAngle(po.Rotate.Angle)
+
+	srcWidth, srcHeight, angle, flip := extractMeta(img, rightAngle, po.AutoRotate)
+	cropWidth := relativeToAbsoluteSize(po.Crop.Width, srcWidth)
+	cropHeight := relativeToAbsoluteSize(po.Crop.Height, srcHeight)
+
+	if po.Crop.Ratio > 0 {
+		cropWidth, cropHeight = calcRatioCropSize(
+			minNonZeroInt(cropWidth, srcWidth),
+			minNonZeroInt(cropHeight, srcHeight),
+			po.Crop.Ratio,
+		)
+	}
 
 	cropGravity := po.Crop.Gravity
 	if cropGravity.Type == gravityUnknown {
@@ -332,32 +744,46 @@ func transformImage(ctx context.Context, img *vipsImage, data []byte, po *proces
 	widthToScale := minNonZeroInt(cropWidth, srcWidth)
 	heightToScale := minNonZeroInt(cropHeight, srcHeight)
 
-	scale := calcScale(widthToScale, heightToScale, po, imgtype)
+	wscale, hscale := calcScale(widthToScale, heightToScale, po, imgtype)
+
+	// Blur areas are defined relative to the auto-rotated source,
+	// so we need the original scale
+	areaWScale, areaHScale := wscale, hscale
 
-	cropWidth = scaleInt(cropWidth, scale)
-	cropHeight = scaleInt(cropHeight, scale)
+	cropWidth = scaleInt(cropWidth, wscale)
+	cropHeight = scaleInt(cropHeight, hscale)
 	if cropGravity.Type != gravityFocusPoint {
-		cropGravity.X *= scale
-		cropGravity.Y *= scale
+		cropGravity.X = relativeToAbsoluteOffset(cropGravity.X, srcWidth) * wscale
+		cropGravity.Y = relativeToAbsoluteOffset(cropGravity.Y, srcHeight) * hscale
 	}
 
-	if !trimmed && scale != 1 && data != nil && canScaleOnLoad(imgtype, scale) {
-		jpegShrink := calcJpegShink(scale, imgtype)
+	// Scale-on-load can't be different by axes, so we don't shrink more than needed
+	loadScale := math.Max(wscale, hscale)
+
+	if !trimmed && loadScale != 1 && data != nil && canScaleOnLoad(imgtype, loadScale) {
+		jpegShrink := calcJpegShink(loadScale, imgtype)
 
 		if imgtype != imageTypeJPEG || jpegShrink != 1 {
 			// Do some scale-on-load
-			if err = img.Load(data, imgtype, jpegShrink, scale, 1); err != nil {
+			if err = img.Load(data, imgtype, jpegShrink, loadScale*sourceScale(imgtype, po), po.Page, 1); err != nil {
 				return err
 			}
+
+			// PDF page is rendered at the new scale, so we need to check its dimensions again
+			if imgtype == imageTypePDF {
+				if err = checkDimensions(img.Width(), img.Height()); err != nil {
+					return err
+				}
+			}
 		}
 
 		// Update scale after scale-on-load
-		newWidth, newHeight, _, _ := extractMeta(img)
+		newWidth, newHeight, _, _ := extractMeta(img, rightAngle, po.AutoRotate)
 
 		widthToScale = scaleInt(widthToScale, float64(newWidth)/float64(srcWidth))
 		heightToScale = scaleInt(heightToScale, float64(newHeight)/float64(srcHeight))
 
-		scale = calcScale(widthToScale, heightToScale, po, imgtype)
+		wscale, hscale = calcScale(widthToScale, heightToScale, po, imgtype)
 	}
 
 	if err = img.Rad2Float(); err != nil {
@@ -365,7 +791,7 @@ func transformImage(ctx context.Context, img *vipsImage, data []byte, po *proces
 	}
 
 	iccImported := false
-	convertToLinear := conf.UseLinearColorspace && (scale != 1 || po.Dpr != 1)
+	convertToLinear := conf.UseLinearColorspace && (wscale != 1 || hscale != 1 || po.Dpr != 1)
 
 	if convertToLinear || !img.IsSRGB() {
 		if err = img.ImportColourProfile(true); err != nil {
@@ -386,8 +812,14 @@ func transformImage(ctx context.Context, img *vipsImage, data []byte, po *proces
 
 	hasAlpha := img.HasAlpha()
 
-	if scale != 1 {
-		if err = img.Resize(scale, hasAlpha); err != nil {
+	if wscale != 1 || hscale != 1 {
+		// The image is not rotated yet, so the axes may be swapped
+		if (angle+rightAngle/90)%2 == 1 {
+			err = img.Resize(hscale, wscale, hasAlpha)
+		} else {
+			err = img.Resize(wscale, hscale, hasAlpha)
+		}
+		if err != nil {
 			return err
 		}
 	}
@@ -408,13 +840,61 @@ func transformImage(ctx context.Context, img *vipsImage, data []byte, po *proces
 		}
 	}
 
+	if len(po.BlurAreas) > 0 {
+		// The image is not rotated with the rotate option yet, so the axes may be swapped
+		if (rightAngle/90)%2 == 1 {
+			err = blurAreas(img, po.BlurAreas, areaHScale, areaWScale)
+		} else {
+			err = blurAreas(img, po.BlurAreas, areaWScale, areaHScale)
+		}
+		if err != nil {
+			return err
+		}
+		if err = copyMemoryAndCheckTimeout(ctx, img); err != nil {
+			return err
+		}
+	}
+
+	if rightAngle > 0 {
+		if err = img.Rotate(rightAngle / 90); err != nil {
+			return err
+		}
+	}
+
+	if po.Flip.Horizontal {
+		if err = img.Flip(); err != nil {
+			return err
+		}
+	}
+
+	if po.Flip.Vertical {
+		if err = img.FlipVertical(); err != nil {
+			return err
+		}
+	}
+
+	if extraAngle != 0 {
+		if err = rotateImage(img, extraAngle, po); err != nil {
+			return err
+		}
+		if err = copyMemoryAndCheckTimeout(ctx, img); err != nil {
+			return err
+		}
+	}
+
 	dprWidth := scaleInt(po.Width, po.Dpr)
 	dprHeight := scaleInt(po.Height, po.Dpr)
 
 	if err = cropImage(img, cropWidth, cropHeight, &cropGravity); err != nil {
 		return err
 	}
-	if err = cropImage(img, dprWidth, dprHeight, &po.Gravity); err != nil {
+	gravity := po.Gravity
+	if gravity.Type != gravityFocusPoint {
+		gravity.X = relativeToAbsoluteOffset(gravity.X, img.Width())
+		gravity.Y = relativeToAbsoluteOffset(gravity.Y, img.Height())
+	}
+
+	if err = cropImage(img, dprWidth, dprHeight, &gravity); err != nil {
 		return err
 	}
 
@@ -422,7 +902,7 @@ func transformImage(ctx context.Context, img *vipsImage, data []byte, po *proces
 		webpLimitShrink := float64(maxInt(img.Width(), img.Height())) / webpMaxDimension
 
 		if webpLimitShrink > 1.0 {
-			if err = img.Resize(1.0/webpLimitShrink, hasAlpha); err != nil {
+			if err = img.Resize(1.0/webpLimitShrink, 1.0/webpLimitShrink, hasAlpha); err != nil {
 				return err
 			}
 			logWarning("WebP dimension size is limited to %d. The image is rescaled to %dx%d", int(webpMaxDimension), img.Width(), img.Height())
@@ -455,6 +935,28 @@ func transformImage(ctx context.Context, img *vipsImage, data []byte, po *proces
 		return err
 	}
 
+	if po.Brightness != 0 || po.Contrast != 1 {
+		if err = img.BrightnessContrast(po.Brightness, po.Contrast); err != nil {
+			return err
+		}
+	}
+
+	if po.Saturation != 1 {
+		if err = img.Saturation(po.Saturation); err != nil {
+			return err
+		}
+	}
+
+	if po.Gamma != 1 {
+		if err = img.Gamma(po.Gamma); err != nil {
+			return err
+		}
+	}
+
+	if err = applyColorEffects(img, po); err != nil {
+		return err
+	}
+
 	if po.Blur > 0 {
 		if err = img.Blur(po.Blur); err != nil {
 			return err
@@ -467,6 +969,12 @@ func transformImage(ctx context.Context, img *vipsImage, data []byte, po *proces
 		}
 	}
 
+	if pixelSize := scaleInt(po.Pixelate, po.Dpr); pixelSize > 1 {
+		if err = img.Pixelate(pixelSize); err != nil {
+			return err
+		}
+	}
+
 	if err = copyMemoryAndCheckTimeout(ctx, img); err != nil {
 		return err
 	}
@@ -479,10 +987,10 @@ func transformImage(ctx context.Context, img *vipsImage, data []byte, po *proces
 	}
 
 	if po.Padding.Enabled {
-		paddingTop := scaleInt(po.Padding.Top, po.Dpr)
-		paddingRight := scaleInt(po.Padding.Right, po.Dpr)
-		paddingBottom := scaleInt(po.Padding.Bottom, po.Dpr)
-		paddingLeft := scaleInt(po.Padding.Left, po.Dpr)
+		paddingTop := calcPadding(po.Padding.Top, img.Height(), po.Dpr)
+		paddingRight := calcPadding(po.Padding.Right, img.Width(), po.Dpr)
+		paddingBottom := calcPadding(po.Padding.Bottom, img.Height(), po.Dpr)
+		paddingLeft := calcPadding(po.Padding.Left, img.Width(), po.Dpr)
 		if err = img.Embed(
 			img.Width()+paddingLeft+paddingRight,
 			img.Height()+paddingTop+paddingBottom,
@@ -495,12 +1003,42 @@ func transformImage(ctx context.Context, img *vipsImage, data []byte, po *proces
 		}
 	}
 
-	if po.Watermark.Enabled && watermark != nil {
-		if err = applyWatermark(img, watermark, &po.Watermark, 1); err != nil {
+	if po.CornerRadius > 0 || po.Mask != maskNone {
+		if err = applyMask(img, po, transparentBg); err != nil {
+			return err
+		}
+		if err = copyMemoryAndCheckTimeout(ctx, img); err != nil {
+			return err
+		}
+	}
+
+	if po.Border.Width > 0 {
+		borderWidth := scaleInt(po.Border.Width, po.Dpr)
+		if err = img.Embed(
+			img.Width()+borderWidth*2,
+			img.Height()+borderWidth*2,
+			borderWidth,
+			borderWidth,
+			po.Border.Color,
+			false,
+		); err != nil {
+			return err
+		}
+	}
+
+	if po.Shadow.Enabled && po.Shadow.Opacity > 0 {
+		if err = applyShadow(img, po, transparentBg); err != nil {
+			return err
+		}
+		if err = copyMemoryAndCheckTimeout(ctx, img); err != nil {
 			return err
 		}
 	}
 
+	if err = applyWatermarks(ctx, img, po, 1); err != nil {
+		return err
+	}
+
 	if err = img.RgbColourspace(); err != nil {
 		return err
 	}
@@ -512,6 +1050,48 @@ func transformImage(ctx context.Context, img *vipsImage, data []byte, po *proces
 	return copyMemoryAndCheckTimeout(ctx, img)
 }
 
+// getFrameDelays returns delays (in ms) of the loaded frames. Depending on the version,
+// libvips provides delays of the loaded frames, delays of all the frames of the image,
+// or only a single GIF delay (in cs) for all the frames
+func getFrameDelays(delays []int, gifDelay, loadedFrom, loadedFrames int) []int {
+	switch {
+	case len(delays) == loadedFrames:
+		return delays
+	case len(delays) >= loadedFrom+loadedFrames:
+		return delays[loadedFrom : loadedFrom+loadedFrames]
+	}
+
+	res := make([]int, loadedFrames)
+	for i := range res {
+		res[i] = gifDelay * 10
+	}
+
+	return res
+}
+
+// calcFrameDelays calculates delays of the resulting frames.
+// Delays of the skipped frames are added to the previous taken frame
+// so the animation keeps its duration
+func calcFrameDelays(srcDelays []int, framesCount, step int, speed float64) []int {
+	delays := make([]int, framesCount)
+
+	for i := range delays {
+		sum := 0
+		for j := i * step; j < (i+1)*step && j < len(srcDelays); j++ {
+			sum += srcDelays[j]
+		}
+
+		delays[i] = int(math.Round(float64(sum) / speed))
+
+		// Browsers treat zero delays as ~100ms, so we don't round non-zero delays down to zero
+		if sum > 0 && delays[i] < minFrameDelay {
+			delays[i] = minFrameDelay
+		}
+	}
+
+	return delays
+}
+
 func transformAnimated(ctx context.Context, img *vipsImage, data []byte, po *processingOptions, imgtype imageType) error {
 	if po.Trim.Enabled {
 		logWarning("Trim is not supported for animated images")
@@ -525,27 +1105,46 @@ func transformAnimated(ctx context.Context, img *vipsImage, data []byte, po *pro
 		return err
 	}
 
-	framesCount := minInt(img.Height()/frameHeight, conf.MaxAnimationFrames)
+	totalFrames := img.Height() / frameHeight
+
+	if po.Frames.Start >= totalFrames {
+		return errFramesOutOfRange
+	}
+
+	framesCount := (totalFrames - po.Frames.Start + po.FrameStep - 1) / po.FrameStep
+	if po.Frames.Count > 0 {
+		framesCount = minInt(framesCount, po.Frames.Count)
+	}
+	framesCount = minInt(framesCount, conf.MaxAnimationFrames)
+
+	// Number of the source frames covered by the resulting frames.
+	// We need the skipped ones too to calculate the resulting delays
+	framesSpan := minInt(framesCount*po.FrameStep, totalFrames-po.Frames.Start)
 
 	// Double check dimensions because animated image has many frames
-	if err = checkDimensions(imgWidth, frameHeight*framesCount); err != nil {
+	if err = checkDimensions(imgWidth, frameHeight*framesSpan); err != nil {
 		return err
 	}
 
+	// Index of the first loaded frame in the source image
+	loadedFrom := 0
+
 	// Vips 8.8+ supports n-pages and doesn't load the whole animated image on header access
 	if nPages, _ := img.GetInt("n-pages"); nPages > 0 {
 		scale := 1.0
 
 		// Don't do scale on load if we need to crop
-		if po.Crop.Width == 0 && po.Crop.Height == 0 {
-			scale = calcScale(imgWidth, frameHeight, po, imgtype)
+		if po.Crop.Width == 0 && po.Crop.Height == 0 && po.Crop.Ratio == 0 {
+			scale = math.Max(calcScale(imgWidth, frameHeight, po, imgtype))
 		}
 
-		if nPages > framesCount || canScaleOnLoad(imgtype, scale) {
+		if po.Frames.Start > 0 || nPages > framesSpan || canScaleOnLoad(imgtype, scale) {
 			// Do some scale-on-load and load only the needed frames
-			if err = img.Load(data, imgtype, 1, scale, framesCount); err != nil {
+			if err = img.Load(data, imgtype, 1, scale, po.Frames.Start, framesSpan); err != nil {
 				return err
 			}
+
+			loadedFrom = po.Frames.Start
 		}
 
 		imgWidth = img.Width()
@@ -556,19 +1155,30 @@ func transformAnimated(ctx context.Context, img *vipsImage, data []byte, po *pro
 		}
 	}
 
-	delay, err := img.GetInt("gif-delay")
+	loadedFrames := img.Height() / frameHeight
+	firstFrame := po.Frames.Start - loadedFrom
+
+	// libvips < 8.9 doesn't provide the delays array, so we can ignore the error
+	srcDelays, _ := img.GetIntSlice("delay")
+
+	gifDelay, err := img.GetInt("gif-delay")
 	if err != nil {
 		return err
 	}
 
-	loop, err := img.GetInt("gif-loop")
-	if err != nil {
-		return err
+	srcDelays = getFrameDelays(srcDelays, gifDelay, loadedFrom, loadedFrames)
+	delays := calcFrameDelays(srcDelays[firstFrame:], framesCount, po.FrameStep, po.Speed)
+
+	loop := po.Loop
+	if loop < 0 {
+		if loop, err = img.GetInt("gif-loop"); err != nil {
+			return err
+		}
 	}
 
-	watermarkEnabled := po.Watermark.Enabled
-	po.Watermark.Enabled = false
-	defer func() { po.Watermark.Enabled = watermarkEnabled }()
+	watermarks := po.Watermarks
+	po.Watermarks = nil
+	defer func() { po.Watermarks = watermarks }()
 
 	frames := make([]*vipsImage, framesCount)
 	defer func() {
@@ -582,7 +1192,7 @@ func transformAnimated(ctx context.Context, img *vipsImage, data []byte, po *pro
 	for i := 0; i < framesCount; i++ {
 		frame := new(vipsImage)
 
-		if err = img.Extract(frame, 0, i*frameHeight, imgWidth, frameHeight); err != nil {
+		if err = img.Extract(frame, 0, (firstFrame+i*po.FrameStep)*frameHeight, imgWidth, frameHeight); err != nil {
 			return err
 		}
 
@@ -601,10 +1211,10 @@ func transformAnimated(ctx context.Context, img *vipsImage, data []byte, po *pro
 		return err
 	}
 
-	if watermarkEnabled && watermark != nil {
-		if err = applyWatermark(img, watermark, &po.Watermark, framesCount); err != nil {
-			return err
-		}
+	po.Watermarks = watermarks
+
+	if err = applyWatermarks(ctx, img, po, framesCount); err != nil {
+		return err
 	}
 
 	if err = img.CastUchar(); err != nil {
@@ -616,7 +1226,9 @@ func transformAnimated(ctx context.Context, img *vipsImage, data []byte, po *pro
 	}
 
 	img.SetInt("page-height", frames[0].Height())
-	img.SetInt("gif-delay", delay)
+	img.SetIntSlice("delay", delays)
+	img.SetInt("gif-delay", int(math.Round(float64(delays[0])/10)))
+	img.SetInt("loop", loop)
 	img.SetInt("gif-loop", loop)
 	img.SetInt("n-pages", framesCount)
 
@@ -703,8 +1315,13 @@ func processImage(ctx context.Context) ([]byte, context.CancelFunc, error) {
 	po := getProcessingOptions(ctx)
 	imgdata := getImageData(ctx)
 
-	if po.Format == imageTypeUnknown {
+	switch {
+	case po.Format == imageTypeUnknown:
 		switch {
+		case po.PreferJxl && imageTypeSaveSupport(imageTypeJXL):
+			po.Format = imageTypeJXL
+		case po.PreferAvif && imageTypeSaveSupport(imageTypeAVIF):
+			po.Format = imageTypeAVIF
 		case po.PreferWebP && imageTypeSaveSupport(imageTypeWEBP):
 			po.Format = imageTypeWEBP
 		case imageTypeSaveSupport(imgdata.Type) && imageTypeGoodForWeb(imgdata.Type):
@@ -712,10 +1329,23 @@ func processImage(ctx context.Context) ([]byte, context.CancelFunc, error) {
 		default:
 			po.Format = imageTypeJPEG
 		}
-	} else if po.EnforceWebP && imageTypeSaveSupport(imageTypeWEBP) {
+
+		// Masked corners and shadows need transparency
+		if (po.CornerRadius > 0 || po.Mask != maskNone || po.Shadow.Enabled) && !po.Format.SupportsAlpha() && !po.Flatten {
+			po.Format = imageTypePNG
+		}
+	case po.EnforceJxl && imageTypeSaveSupport(imageTypeJXL):
+		po.Format = imageTypeJXL
+	case po.EnforceAvif && imageTypeSaveSupport(imageTypeAVIF):
+		po.Format = imageTypeAVIF
+	case po.EnforceWebP && imageTypeSaveSupport(imageTypeWEBP):
 		po.Format = imageTypeWEBP
 	}
 
+	if po.Palette > 0 {
+		po.Format = imageTypePALETTE
+	}
+
 	if po.Format == imageTypeSVG {
 		if imgdata.Type != imageTypeSVG {
 			return []byte{}, func() {}, errConvertingNonSvgToSvg
@@ -751,13 +1381,28 @@ func processImage(ctx context.Context) ([]byte, context.CancelFunc, error) {
 	if po.ResizingType == resizeCrop {
 		logWarning("`crop` resizing type is deprecated and will be removed in future versions. Use `crop` processing option instead")
 
-		po.Crop.Width, po.Crop.Height = po.Width, po.Height
+		po.Crop.Width, po.Crop.Height = float64(po.Width), float64(po.Height)
 
 		po.ResizingType = resizeFit
 		po.Width, po.Height = 0, 0
 	}
 
-	animationSupport := conf.MaxAnimationFrames > 1 && vipsSupportAnimation(imgdata.Type) && vipsSupportAnimation(po.Format)
+	if po.Page > 0 && po.Frames.Start > 0 {
+		return nil, func() {}, errFramesWithPage
+	}
+
+	// Selecting a page of an animated image means we need a single frame
+	animationSupport := conf.MaxAnimationFrames > 1 && po.Page == 0 &&
+		vipsSupportAnimation(imgdata.Type) && vipsSupportAnimation(po.Format)
+
+	// The result can't be animated, so the frames start selects the frame to use
+	if !animationSupport && po.Frames.Start > 0 {
+		if err := checkPage(imgdata.Pages, po.Frames.Start); err != nil {
+			return nil, func() {}, errFramesOutOfRange
+		}
+
+		po.Page = po.Frames.Start
+	}
 
 	pages := 1
 	if animationSupport {
@@ -767,15 +1412,52 @@ func processImage(ctx context.Context) ([]byte, context.CancelFunc, error) {
 	img := new(vipsImage)
 	defer img.Clear()
 
-	if err := img.Load(imgdata.Data, imgdata.Type, 1, 1.0, pages); err != nil {
+	if err := img.Load(imgdata.Data, imgdata.Type, 1, sourceScale(imgdata.Type, po), po.Page, pages); err != nil {
 		return nil, func() {}, err
 	}
 
+	// PDF page dimensions are known only after the page is loaded
+	if imgdata.Type == imageTypePDF {
+		if err := checkDimensions(img.Width(), img.Height()); err != nil {
+			return nil, func() {}, err
+		}
+	}
+
+	if po.Format == imageTypeJXL && imgdata.Type == imageTypeJPEG && canTranscodeToJxl(po, img) {
+		data, err := transcodeJpegToJxlWithMeta(imgdata.Data, po.StripMetadata)
+		if err == nil {
+			if conf.DominantColorHeader {
+				if po.DominantColor, err = calcDominantColor(img); err != nil {
+					return nil, func() {}, err
+				}
+			}
+
+			return data, func() {}, nil
+		}
+
+		logWarning("Can't losslessly recompress JPEG to JPEG XL, falling back to encoding: %s", err)
+	}
+
+	if po.Format.IsPlaceholder() {
+		data, err := generatePlaceholder(ctx, img, imgdata, po)
+		return data, func() {}, err
+	}
+
+	if po.Format == imageTypePALETTE {
+		data, err := generatePalette(ctx, img, imgdata, po)
+		return data, func() {}, err
+	}
+
 	if animationSupport && img.IsAnimated() {
 		if err := transformAnimated(ctx, img, imgdata.Data, po, imgdata.Type); err != nil {
 			return nil, func() {}, err
 		}
 	} else {
+		// A still image has the only frame
+		if po.Frames.Start > 0 && po.Page == 0 {
+			return nil, func() {}, errFramesOutOfRange
+		}
+
 		if err := transformImage(ctx, img, imgdata.Data, po, imgdata.Type); err != nil {
 			return nil, func() {}, err
 		}
@@ -785,6 +1467,15 @@ func processImage(ctx context.Context) ([]byte, context.CancelFunc, error) {
 		return nil, func() {}, err
 	}
 
+	if conf.DominantColorHeader {
+		color, err := calcDominantColor(img)
+		if err != nil {
+			return nil, func() {}, err
+		}
+
+		po.DominantColor = color
+	}
+
 	if po.MaxBytes > 0 && canFitToBytes(po.Format) {
 		return saveImageToFitBytes(po, img)
 	}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ProcessTestSuite struct{ MainTestSuite }
+
+func (s *ProcessTestSuite) TestGetFrameDelays() {
+	testCases := []struct {
+		name         string
+		delays       []int
+		gifDelay     int
+		loadedFrom   int
+		loadedFrames int
+		expected     []int
+	}{
+		{
+			name:         "LoadedFramesDelays",
+			delays:       []int{10, 20, 30},
+			gifDelay:     1,
+			loadedFrom:   2,
+			loadedFrames: 3,
+			expected:     []int{10, 20, 30},
+		},
+		{
+			name:         "AllFramesDelays",
+			delays:       []int{10, 20, 30, 40, 50},
+			gifDelay:     1,
+			loadedFrom:   2,
+			loadedFrames: 2,
+			expected:     []int{30, 40},
+		},
+		{
+			name:         "AllFramesDelaysFromStart",
+			delays:       []int{10, 20, 30, 40, 50},
+			gifDelay:     1,
+			loadedFrom:   0,
+			loadedFrames: 2,
+			expected:     []int{10, 20},
+		},
+		{
+			name:         "NoDelays",
+			delays:       nil,
+			gifDelay:     4,
+			loadedFrom:   0,
+			loadedFrames: 3,
+			expected:     []int{40, 40, 40},
+		},
+		{
+			name:         "NotEnoughDelays",
+			delays:       []int{10, 20},
+			gifDelay:     5,
+			loadedFrom:   1,
+			loadedFrames: 3,
+			expected:     []int{50, 50, 50},
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			delays := getFrameDelays(tc.delays, tc.gifDelay, tc.loadedFrom, tc.loadedFrames)
+			assert.Equal(s.T(), tc.expected, delays)
+		})
+	}
+}
+
+func (s *ProcessTestSuite) TestCalcFrameDelays() {
+	testCases := []struct {
+		name        string
+		srcDelays   []int
+		framesCount int
+		step        int
+		speed       float64
+		expected    []int
+	}{
+		{
+			name:        "Unchanged",
+			srcDelays:   []int{100, 50, 80},
+			framesCount: 3,
+			step:        1,
+			speed:       1,
+			expected:    []int{100, 50, 80},
+		},
+		{
+			name:        "Step",
+			srcDelays:   []int{100, 50, 80, 40, 60},
+			framesCount: 2,
+			step:        2,
+			speed:       1,
+			expected:    []int{150, 120},
+		},
+		{
+			name:        "StepTail",
+			srcDelays:   []int{100, 50, 80, 40, 60},
+			framesCount: 3,
+			step:        2,
+			speed:       1,
+			expected:    []int{150, 120, 60},
+		},
+		{
+			name:        "Speed",
+			srcDelays:   []int{100, 50},
+			framesCount: 2,
+			step:        1,
+			speed:       2,
+			expected:    []int{50, 25},
+		},
+		{
+			name:        "SpeedRounding",
+			srcDelays:   []int{100, 70},
+			framesCount: 2,
+			step:        1,
+			speed:       3,
+			expected:    []int{33, 23},
+		},
+		{
+			name:        "SlowDown",
+			srcDelays:   []int{100, 50},
+			framesCount: 2,
+			step:        1,
+			speed:       0.5,
+			expected:    []int{200, 100},
+		},
+		{
+			name:        "MinDelay",
+			srcDelays:   []int{30, 10},
+			framesCount: 2,
+			step:        1,
+			speed:       2,
+			expected:    []int{minFrameDelay, minFrameDelay},
+		},
+		{
+			name:        "RoundedToZero",
+			srcDelays:   []int{1, 2},
+			framesCount: 2,
+			step:        1,
+			speed:       10,
+			expected:    []int{minFrameDelay, minFrameDelay},
+		},
+		{
+			name:        "ZeroDelay",
+			srcDelays:   []int{0, 0},
+			framesCount: 2,
+			step:        1,
+			speed:       2,
+			expected:    []int{0, 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			delays := calcFrameDelays(tc.srcDelays, tc.framesCount, tc.step, tc.speed)
+			assert.Equal(s.T(), tc.expected, delays)
+		})
+	}
+}
+
+func TestProcess(t *testing.T) {
+	suite.Run(t, new(ProcessTestSuite))
+}
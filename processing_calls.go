@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type processingCall struct {
+	done chan struct{}
+
+	entry *resultCacheEntry
+	err   error
+}
+
+type processingCallGroup struct {
+	calls map[string]*processingCall
+	mutex sync.Mutex
+}
+
+var processingCalls = processingCallGroup{calls: make(map[string]*processingCall)}
+
+// Join returns the in-flight call for the key. The second result is true when
+// there was no such call and the caller became its leader.
+func (g *processingCallGroup) Join(key string) (*processingCall, bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if call, ok := g.calls[key]; ok {
+		return call, false
+	}
+
+	call := &processingCall{done: make(chan struct{})}
+	g.calls[key] = call
+
+	return call, true
+}
+
+// Finish publishes the leader's result to the followers. rerr is the value
+// recovered from the leader's panic, if any.
+func (g *processingCallGroup) Finish(key string, call *processingCall, entry *resultCacheEntry, rerr interface{}) {
+	g.mutex.Lock()
+	delete(g.calls, key)
+	g.mutex.Unlock()
+
+	call.entry = entry
+
+	if err, ok := rerr.(error); ok {
+		call.err = err
+	}
+
+	close(call.done)
+}
+
+// Wait waits for the leader's result respecting the follower's own write timeout.
+// It returns nil entry and nil error when the leader has finished without
+// a result the follower can reuse. In this case, the follower should retry.
+func (call *processingCall) Wait(ctx context.Context) (*resultCacheEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(conf.WriteTimeout)*time.Second)
+	defer cancel()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		checkTimeout(ctx)
+	}
+
+	if ierr, ok := call.err.(*imgproxyError); ok && ierr.StatusCode == 499 {
+		// The leader's client has gone. This doesn't affect followers
+		return nil, nil
+	}
+
+	return call.entry, call.err
+}
@@ -33,7 +33,7 @@ func initProcessingHandler() error {
 
 	vary := make([]string, 0)
 
-	if conf.EnableWebpDetection || conf.EnforceWebp {
+	if conf.EnableWebpDetection || conf.EnforceWebp || conf.EnableAvifDetection || conf.EnforceAvif || conf.EnableJxlDetection || conf.EnforceJxl {
 		vary = append(vary, "Accept")
 	}
 
@@ -51,6 +51,12 @@ func initProcessingHandler() error {
 		return err
 	}
 
+	if err = initResultCache(); err != nil {
+		return err
+	}
+
+	initWatermarksCache()
+
 	return nil
 }
 
@@ -58,15 +64,23 @@ func respondWithImage(ctx context.Context, reqID string, r *http.Request, rw htt
 	po := getProcessingOptions(ctx)
 
 	var contentDisposition string
-	if len(po.Filename) > 0 {
+	switch {
+	case len(po.Filename) > 0:
 		contentDisposition = po.Format.ContentDisposition(po.Filename)
-	} else {
+	case po.SourceURLEncrypted:
+		// Don't reveal the source URL that was encrypted
+		contentDisposition = po.Format.ContentDisposition(contentDispositionFilenameFallback)
+	default:
 		contentDisposition = po.Format.ContentDispositionFromURL(getImageURL(ctx))
 	}
 
 	rw.Header().Set("Content-Type", po.Format.Mime())
 	rw.Header().Set("Content-Disposition", contentDisposition)
 
+	if len(po.DominantColor) > 0 {
+		rw.Header().Set(dominantColorHeader, po.DominantColor)
+	}
+
 	var cacheControl, expires string
 
 	if conf.CacheControlPassthrough {
@@ -75,8 +89,15 @@ func respondWithImage(ctx context.Context, reqID string, r *http.Request, rw htt
 	}
 
 	if len(cacheControl) == 0 && len(expires) == 0 {
-		cacheControl = fmt.Sprintf("max-age=%d, public", conf.TTL)
-		expires = time.Now().Add(time.Second * time.Duration(conf.TTL)).Format(http.TimeFormat)
+		ttl := conf.TTL
+
+		// Don't let caches keep the image after the URL has expired
+		if po.Expires > 0 {
+			ttl = minInt(ttl, maxInt(int(po.Expires-time.Now().Unix()), 0))
+		}
+
+		cacheControl = fmt.Sprintf("max-age=%d, public", ttl)
+		expires = time.Now().Add(time.Second * time.Duration(ttl)).Format(http.TimeFormat)
 	}
 
 	if len(cacheControl) > 0 {
@@ -125,6 +146,38 @@ func respondWithNotModified(ctx context.Context, reqID string, r *http.Request,
 	logResponse(reqID, r, 304, nil, &imageURL, getProcessingOptions(ctx))
 }
 
+func respondWithCachedResult(ctx context.Context, reqID string, r *http.Request, rw http.ResponseWriter, entry *resultCacheEntry) {
+	po := getProcessingOptions(ctx)
+	po.Format = entry.Format
+	po.DominantColor = entry.DominantColor
+
+	ctx = context.WithValue(ctx, cacheControlHeaderCtxKey, entry.CacheControl)
+	ctx = context.WithValue(ctx, expiresHeaderCtxKey, entry.Expires)
+
+	if len(entry.ETag) > 0 {
+		rw.Header().Set("ETag", entry.ETag)
+
+		if entry.ETag == r.Header.Get("If-None-Match") {
+			respondWithNotModified(ctx, reqID, r, rw)
+			return
+		}
+	}
+
+	respondWithImage(ctx, reqID, r, rw, entry.Data)
+}
+
+func newResultCacheEntry(ctx context.Context, data []byte, eTag string) *resultCacheEntry {
+	return &resultCacheEntry{
+		Data:          append([]byte(nil), data...),
+		Format:        getProcessingOptions(ctx).Format,
+		DominantColor: getProcessingOptions(ctx).DominantColor,
+		CacheControl:  getCacheControlHeader(ctx),
+		Expires:       getExpiresHeader(ctx),
+		ETag:          eTag,
+		CreatedAt:     time.Now(),
+	}
+}
+
 func handleProcessing(reqID string, rw http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -139,16 +192,63 @@ func handleProcessing(reqID string, rw http.ResponseWriter, r *http.Request) {
 		defer startPrometheusDuration(prometheusRequestDuration)()
 	}
 
+	ctx, err := parsePath(ctx, r)
+	if err != nil {
+		panic(err)
+	}
+
+	var resultKey string
+
+	if resultCache != nil || conf.RequestCoalescingEnabled {
+		resultKey = resultCacheKey(r, getProcessingOptions(ctx))
+	}
+
+	if resultCache != nil {
+		if entry, ok := resultCache.Get(resultKey); ok {
+			respondWithCachedResult(ctx, reqID, r, rw, entry)
+			return
+		}
+	}
+
+	var (
+		call         *processingCall
+		callFinished bool
+	)
+
+	if conf.RequestCoalescingEnabled {
+		var leader bool
+
+		for call, leader = processingCalls.Join(resultKey); !leader; call, leader = processingCalls.Join(resultKey) {
+			entry, err := call.Wait(ctx)
+			if err != nil {
+				panic(err)
+			}
+			if entry != nil {
+				respondWithCachedResult(ctx, reqID, r, rw, entry)
+				return
+			}
+		}
+
+		// The result is published as soon as it's ready so followers don't wait
+		// for the response to be written. This is only for the error path
+		defer func() {
+			rerr := recover()
+			if !callFinished {
+				processingCalls.Finish(resultKey, call, nil, rerr)
+			}
+			if rerr != nil {
+				panic(rerr)
+			}
+		}()
+	}
+
 	processingSem <- struct{}{}
 	defer func() { <-processingSem }()
 
 	ctx, timeoutCancel := context.WithTimeout(ctx, time.Duration(conf.WriteTimeout)*time.Second)
 	defer timeoutCancel()
 
-	ctx, err := parsePath(ctx, r)
-	if err != nil {
-		panic(err)
-	}
+	cacheResult := resultCache != nil
 
 	ctx, downloadcancel, err := downloadImage(ctx)
 	defer downloadcancel()
@@ -170,15 +270,42 @@ func handleProcessing(reqID string, rw http.ResponseWriter, r *http.Request) {
 
 		logWarning("Could not load image. Using fallback image: %s", err.Error())
 		ctx = context.WithValue(ctx, imageDataCtxKey, fallbackImage)
+
+		// Don't cache the fallback image as a result for the requested one
+		cacheResult = false
+	} else {
+		imgdata := getImageData(ctx)
+
+		if err = checkPage(imgdata.Pages, getProcessingOptions(ctx).Page); err != nil {
+			panic(err)
+		}
+	}
+
+	checkTimeout(ctx)
+
+	if ctx, err = downloadWatermarks(ctx); err != nil {
+		if prometheusEnabled {
+			incrementPrometheusErrorsTotal("download")
+		}
+		panic(err)
 	}
 
 	checkTimeout(ctx)
 
+	var (
+		eTag        string
+		notModified bool
+	)
+
 	if conf.ETagEnabled {
-		eTag := calcETag(ctx)
+		eTag = calcETag(ctx)
 		rw.Header().Set("ETag", eTag)
 
-		if eTag == r.Header.Get("If-None-Match") {
+		notModified = eTag == r.Header.Get("If-None-Match")
+
+		// Followers are waiting for the result, so the leader should process
+		// the image and publish it before responding with 304
+		if notModified && call == nil {
 			respondWithNotModified(ctx, reqID, r, rw)
 			return
 		}
@@ -190,10 +317,21 @@ func handleProcessing(reqID string, rw http.ResponseWriter, r *http.Request) {
 		imgdata := getImageData(ctx)
 		po := getProcessingOptions(ctx)
 
-		if imgdata.Type == po.Format || po.Format == imageTypeUnknown {
+		if po.Palette == 0 && (imgdata.Type == po.Format || po.Format == imageTypeUnknown) {
 			for _, f := range conf.SkipProcessingFormats {
 				if f == imgdata.Type {
 					po.Format = imgdata.Type
+
+					if call != nil {
+						processingCalls.Finish(resultKey, call, newResultCacheEntry(ctx, imgdata.Data, eTag), nil)
+						callFinished = true
+					}
+
+					if notModified {
+						respondWithNotModified(ctx, reqID, r, rw)
+						return
+					}
+
 					respondWithImage(ctx, reqID, r, rw, imgdata.Data)
 					return
 				}
@@ -215,5 +353,23 @@ func handleProcessing(reqID string, rw http.ResponseWriter, r *http.Request) {
 
 	checkTimeout(ctx)
 
+	if cacheResult || call != nil {
+		entry := newResultCacheEntry(ctx, imageData, eTag)
+
+		if cacheResult {
+			resultCache.Set(resultKey, entry)
+		}
+
+		if call != nil {
+			processingCalls.Finish(resultKey, call, entry, nil)
+			callFinished = true
+		}
+	}
+
+	if notModified {
+		respondWithNotModified(ctx, reqID, r, rw)
+		return
+	}
+
 	respondWithImage(ctx, reqID, r, rw, imageData)
 }
@@ -5,12 +5,15 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/imgproxy/imgproxy/v2/structdiff"
 )
@@ -75,6 +78,18 @@ var resizeTypes = map[string]resizeType{
 	"auto": resizeAuto,
 }
 
+type maskType int
+
+const (
+	maskNone maskType = iota
+	maskCircle
+)
+
+var maskTypes = map[string]maskType{
+	"none":   maskNone,
+	"circle": maskCircle,
+}
+
 type rgbColor struct{ R, G, B uint8 }
 
 var hexColorRegex = regexp.MustCompile("^([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$")
@@ -95,17 +110,32 @@ type extendOptions struct {
 }
 
 type cropOptions struct {
-	Width   int
-	Height  int
+	Width   float64
+	Height  float64
+	Ratio   float64
 	Gravity gravityOptions
 }
 
 type paddingOptions struct {
 	Enabled bool
-	Top     int
-	Right   int
-	Bottom  int
-	Left    int
+	Top     float64
+	Right   float64
+	Bottom  float64
+	Left    float64
+}
+
+type borderOptions struct {
+	Width int
+	Color rgbColor
+}
+
+type shadowOptions struct {
+	Enabled bool
+	Blur    float64
+	OffsetX int
+	OffsetY int
+	Color   rgbColor
+	Opacity float64
 }
 
 type trimOptions struct {
@@ -117,24 +147,76 @@ type trimOptions struct {
 	EqualVer  bool
 }
 
+type rotateOptions struct {
+	Angle float64
+	Crop  bool
+}
+
+type flipOptions struct {
+	Horizontal bool
+	Vertical   bool
+}
+
+type blurAreaOptions struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+	Sigma  float32
+}
+
+type duotoneOptions struct {
+	Enabled   bool
+	Shadow    rgbColor
+	Highlight rgbColor
+}
+
+type tintOptions struct {
+	Enabled  bool
+	Color    rgbColor
+	Strength float64
+}
+
+type framesOptions struct {
+	Start int
+	Count int
+}
+
+type watermarkTextOptions struct {
+	Text  string
+	Font  string
+	Size  int
+	Color rgbColor
+}
+
 type watermarkOptions struct {
 	Enabled   bool
 	Opacity   float64
 	Replicate bool
 	Gravity   gravityOptions
 	Scale     float64
+	Text      watermarkTextOptions
+	URL       string
 }
 
 type processingOptions struct {
 	ResizingType  resizeType
 	Width         int
 	Height        int
+	MinWidth      int
+	MinHeight     int
+	ZoomWidth     float64
+	ZoomHeight    float64
 	Dpr           float64
 	Gravity       gravityOptions
 	Enlarge       bool
 	Extend        extendOptions
 	Crop          cropOptions
 	Padding       paddingOptions
+	CornerRadius  int
+	Mask          maskType
+	Border        borderOptions
+	Shadow        shadowOptions
 	Trim          trimOptions
 	Format        imageType
 	Quality       int
@@ -142,18 +224,53 @@ type processingOptions struct {
 	Flatten       bool
 	Background    rgbColor
 	Blur          float32
+	BlurAreas     []blurAreaOptions
 	Sharpen       float32
+	Pixelate      int
+	Brightness    int
+	Contrast      float64
+	Saturation    float64
+	Gamma         float64
+	Grayscale     bool
+	Sepia         bool
+	Duotone       duotoneOptions
+	Tint          tintOptions
 	StripMetadata bool
+	AutoRotate    bool
+	Rotate        rotateOptions
+	Flip          flipOptions
 
 	CacheBuster string
+	Expires     int64
 
-	Watermark watermarkOptions
+	Watermarks []watermarkOptions
 
 	PreferWebP  bool
 	EnforceWebP bool
+	PreferAvif  bool
+	EnforceAvif bool
+	PreferJxl   bool
+	EnforceJxl  bool
+
+	Page int
+	Dpi  float64
+
+	Frames    framesOptions
+	FrameStep int
+	Speed     float64
+	Loop      int
 
 	Filename string
 
+	Placeholder imageType
+	Palette     int
+
+	// DominantColor is not an option but is filled during processing
+	DominantColor string
+
+	// SourceURLEncrypted is not an option but is filled during parsing
+	SourceURLEncrypted bool
+
 	UsedPresets []string
 }
 
@@ -161,9 +278,14 @@ const (
 	imageURLCtxKey          = ctxKey("imageUrl")
 	processingOptionsCtxKey = ctxKey("processingOptions")
 	urlTokenPlain           = "plain"
+	urlTokenEncrypted       = "enc"
 	maxClientHintDPR        = 8
+	maxWatermarkLayers      = 16
+	maxWatermarkTextLength  = 256
+	maxWatermarkTextSize    = 512
 
 	msgForbidden     = "Forbidden"
+	msgExpiredURL    = "Expired URL"
 	msgInvalidURL    = "Invalid URL"
 	msgInvalidSource = "Invalid Source"
 )
@@ -204,6 +326,24 @@ func (rt resizeType) MarshalJSON() ([]byte, error) {
 	return []byte("null"), nil
 }
 
+func (mt maskType) String() string {
+	for k, v := range maskTypes {
+		if v == mt {
+			return k
+		}
+	}
+	return ""
+}
+
+func (mt maskType) MarshalJSON() ([]byte, error) {
+	for k, v := range maskTypes {
+		if v == mt {
+			return []byte(fmt.Sprintf("%q", k)), nil
+		}
+	}
+	return []byte("null"), nil
+}
+
 var (
 	_newProcessingOptions    processingOptions
 	newProcessingOptionsOnce sync.Once
@@ -226,8 +366,18 @@ func newProcessingOptions() *processingOptions {
 			Background:    rgbColor{255, 255, 255},
 			Blur:          0,
 			Sharpen:       0,
+			ZoomWidth:     1,
+			ZoomHeight:    1,
 			Dpr:           1,
-			Watermark:     watermarkOptions{Opacity: 1, Replicate: false, Gravity: gravityOptions{Type: gravityCenter}},
+			Dpi:           72,
+			FrameStep:     1,
+			Speed:         1,
+			Loop:          -1,
+			Contrast:      1,
+			Saturation:    1,
+			Gamma:         1,
+			Tint:          tintOptions{Enabled: false, Strength: 1},
+			AutoRotate:    conf.AutoRotate,
 			StripMetadata: conf.StripMetadata,
 		}
 	})
@@ -238,6 +388,25 @@ func newProcessingOptions() *processingOptions {
 	return &po
 }
 
+func newWatermarkOptions() watermarkOptions {
+	return watermarkOptions{
+		Opacity:   1,
+		Replicate: false,
+		Gravity:   gravityOptions{Type: gravityCenter},
+		Text:      watermarkTextOptions{Font: "sans", Size: 24},
+	}
+}
+
+// watermarkLayer returns the watermark layer with the provided index
+// creating the missing layers if needed
+func (po *processingOptions) watermarkLayer(idx int) *watermarkOptions {
+	for len(po.Watermarks) <= idx {
+		po.Watermarks = append(po.Watermarks, newWatermarkOptions())
+	}
+
+	return &po.Watermarks[idx]
+}
+
 func (po *processingOptions) isPresetUsed(name string) bool {
 	for _, usedName := range po.UsedPresets {
 		if usedName == name {
@@ -338,6 +507,39 @@ func decodePlainURL(parts []string) (string, string, error) {
 	return fullURL, format, nil
 }
 
+func decodeEncryptedURL(parts []string) (string, string, error) {
+	var format string
+
+	encoded := strings.Join(parts, "")
+	urlParts := strings.Split(encoded, ".")
+
+	if len(urlParts[0]) == 0 {
+		return "", "", errors.New("Image URL is empty")
+	}
+
+	if len(urlParts) > 2 {
+		return "", "", fmt.Errorf("Multiple formats are specified: %s", encoded)
+	}
+
+	if len(urlParts) == 2 && len(urlParts[1]) > 0 {
+		format = urlParts[1]
+	}
+
+	encrypted, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(urlParts[0], "="))
+	if err != nil {
+		return "", "", fmt.Errorf("Invalid url encoding: %s", encoded)
+	}
+
+	imageURL, err := decryptSourceURL(encrypted)
+	if err != nil {
+		return "", "", err
+	}
+
+	fullURL := fmt.Sprintf("%s%s", conf.BaseURL, imageURL)
+
+	return fullURL, format, nil
+}
+
 func decodeURL(parts []string) (string, string, error) {
 	if len(parts) == 0 {
 		return "", "", errors.New("Image URL is empty")
@@ -347,9 +549,17 @@ func decodeURL(parts []string) (string, string, error) {
 		return decodePlainURL(parts[1:])
 	}
 
+	if isEncryptedURL(parts) {
+		return decodeEncryptedURL(parts[1:])
+	}
+
 	return decodeBase64URL(parts)
 }
 
+func isEncryptedURL(parts []string) bool {
+	return len(parts) > 1 && parts[0] == urlTokenEncrypted
+}
+
 func parseDimension(d *int, name, arg string) error {
 	if v, err := strconv.Atoi(arg); err == nil && v >= 0 {
 		*d = v
@@ -360,6 +570,18 @@ func parseDimension(d *int, name, arg string) error {
 	return nil
 }
 
+// parseRelativeDimension parses a dimension that can be either an integer
+// number of pixels or a fraction of the base size between 0 and 1
+func parseRelativeDimension(d *float64, name, arg string) error {
+	if v, err := strconv.ParseFloat(arg, 64); err == nil && v >= 0 && (v < 1 || v == math.Trunc(v)) {
+		*d = v
+	} else {
+		return fmt.Errorf("Invalid %s: %s", name, arg)
+	}
+
+	return nil
+}
+
 func parseBoolOption(str string) bool {
 	b, err := strconv.ParseBool(str)
 
@@ -432,6 +654,45 @@ func applyHeightOption(po *processingOptions, args []string) error {
 	return parseDimension(&po.Height, "height", args[0])
 }
 
+func applyMinWidthOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid min width arguments: %v", args)
+	}
+
+	return parseDimension(&po.MinWidth, "min width", args[0])
+}
+
+func applyMinHeightOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid min height arguments: %v", args)
+	}
+
+	return parseDimension(&po.MinHeight, "min height", args[0])
+}
+
+func applyZoomOption(po *processingOptions, args []string) error {
+	if len(args) > 2 {
+		return fmt.Errorf("Invalid zoom arguments: %v", args)
+	}
+
+	if z, err := strconv.ParseFloat(args[0], 64); err == nil && z > 0 {
+		po.ZoomWidth = z
+		po.ZoomHeight = z
+	} else {
+		return fmt.Errorf("Invalid zoom value: %s", args[0])
+	}
+
+	if len(args) > 1 && len(args[1]) > 0 {
+		if z, err := strconv.ParseFloat(args[1], 64); err == nil && z > 0 {
+			po.ZoomHeight = z
+		} else {
+			return fmt.Errorf("Invalid zoom value: %s", args[1])
+		}
+	}
+
+	return nil
+}
+
 func applyEnlargeOption(po *processingOptions, args []string) error {
 	if len(args) > 1 {
 		return fmt.Errorf("Invalid enlarge arguments: %v", args)
@@ -542,6 +803,98 @@ func applyDprOption(po *processingOptions, args []string) error {
 	return nil
 }
 
+func applyPageOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid page arguments: %v", args)
+	}
+
+	if p, err := strconv.Atoi(args[0]); err == nil && p >= 0 {
+		po.Page = p
+	} else {
+		return fmt.Errorf("Invalid page: %s", args[0])
+	}
+
+	return nil
+}
+
+func applyDpiOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid dpi arguments: %v", args)
+	}
+
+	if d, err := strconv.ParseFloat(args[0], 64); err == nil && d > 0 {
+		po.Dpi = d
+	} else {
+		return fmt.Errorf("Invalid dpi: %s", args[0])
+	}
+
+	return nil
+}
+
+func applyFramesOption(po *processingOptions, args []string) error {
+	if len(args) > 2 {
+		return fmt.Errorf("Invalid frames arguments: %v", args)
+	}
+
+	if s, err := strconv.Atoi(args[0]); err == nil && s >= 0 {
+		po.Frames.Start = s
+	} else {
+		return fmt.Errorf("Invalid frames start: %s", args[0])
+	}
+
+	if len(args) > 1 && len(args[1]) > 0 {
+		if c, err := strconv.Atoi(args[1]); err == nil && c >= 0 {
+			po.Frames.Count = c
+		} else {
+			return fmt.Errorf("Invalid frames count: %s", args[1])
+		}
+	}
+
+	return nil
+}
+
+func applyFrameStepOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid frame step arguments: %v", args)
+	}
+
+	if s, err := strconv.Atoi(args[0]); err == nil && s > 0 {
+		po.FrameStep = s
+	} else {
+		return fmt.Errorf("Invalid frame step: %s", args[0])
+	}
+
+	return nil
+}
+
+func applySpeedOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid speed arguments: %v", args)
+	}
+
+	if s, err := strconv.ParseFloat(args[0], 64); err == nil && s > 0 {
+		po.Speed = s
+	} else {
+		return fmt.Errorf("Invalid speed: %s", args[0])
+	}
+
+	return nil
+}
+
+func applyLoopOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid loop arguments: %v", args)
+	}
+
+	if l, err := strconv.Atoi(args[0]); err == nil && l >= 0 {
+		po.Loop = l
+	} else {
+		return fmt.Errorf("Invalid loop: %s", args[0])
+	}
+
+	return nil
+}
+
 func applyGravityOption(po *processingOptions, args []string) error {
 	return parseGravity(&po.Gravity, args)
 }
@@ -551,12 +904,12 @@ func applyCropOption(po *processingOptions, args []string) error {
 		return fmt.Errorf("Invalid crop arguments: %v", args)
 	}
 
-	if err := parseDimension(&po.Crop.Width, "crop width", args[0]); err != nil {
+	if err := parseRelativeDimension(&po.Crop.Width, "crop width", args[0]); err != nil {
 		return err
 	}
 
 	if len(args) > 1 {
-		if err := parseDimension(&po.Crop.Height, "crop height", args[1]); err != nil {
+		if err := parseRelativeDimension(&po.Crop.Height, "crop height", args[1]); err != nil {
 			return err
 		}
 	}
@@ -568,6 +921,36 @@ func applyCropOption(po *processingOptions, args []string) error {
 	return nil
 }
 
+func applyCropRatioOption(po *processingOptions, args []string) error {
+	if len(args) > 5 {
+		return fmt.Errorf("Invalid crop ratio arguments: %v", args)
+	}
+
+	if len(args) == 1 && args[0] == "0" {
+		po.Crop.Ratio = 0
+		return nil
+	}
+
+	if len(args) < 2 {
+		return fmt.Errorf("Invalid crop ratio arguments: %v", args)
+	}
+
+	w, werr := strconv.ParseFloat(args[0], 64)
+	h, herr := strconv.ParseFloat(args[1], 64)
+
+	if werr != nil || herr != nil || w <= 0 || h <= 0 {
+		return fmt.Errorf("Invalid crop ratio: %s:%s", args[0], args[1])
+	}
+
+	po.Crop.Ratio = w / h
+
+	if len(args) > 2 {
+		return parseGravity(&po.Crop.Gravity, args[2:])
+	}
+
+	return nil
+}
+
 func applyPaddingOption(po *processingOptions, args []string) error {
 	nArgs := len(args)
 
@@ -578,7 +961,7 @@ func applyPaddingOption(po *processingOptions, args []string) error {
 	po.Padding.Enabled = true
 
 	if nArgs > 0 && len(args[0]) > 0 {
-		if err := parseDimension(&po.Padding.Top, "padding top (+all)", args[0]); err != nil {
+		if err := parseRelativeDimension(&po.Padding.Top, "padding top (+all)", args[0]); err != nil {
 			return err
 		}
 		po.Padding.Right = po.Padding.Top
@@ -587,20 +970,20 @@ func applyPaddingOption(po *processingOptions, args []string) error {
 	}
 
 	if nArgs > 1 && len(args[1]) > 0 {
-		if err := parseDimension(&po.Padding.Right, "padding right (+left)", args[1]); err != nil {
+		if err := parseRelativeDimension(&po.Padding.Right, "padding right (+left)", args[1]); err != nil {
 			return err
 		}
 		po.Padding.Left = po.Padding.Right
 	}
 
 	if nArgs > 2 && len(args[2]) > 0 {
-		if err := parseDimension(&po.Padding.Bottom, "padding bottom", args[2]); err != nil {
+		if err := parseRelativeDimension(&po.Padding.Bottom, "padding bottom", args[2]); err != nil {
 			return err
 		}
 	}
 
 	if nArgs > 3 && len(args[3]) > 0 {
-		if err := parseDimension(&po.Padding.Left, "padding left", args[3]); err != nil {
+		if err := parseRelativeDimension(&po.Padding.Left, "padding left", args[3]); err != nil {
 			return err
 		}
 	}
@@ -612,6 +995,109 @@ func applyPaddingOption(po *processingOptions, args []string) error {
 	return nil
 }
 
+func applyCornerRadiusOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid corner radius arguments: %v", args)
+	}
+
+	if r, err := strconv.Atoi(args[0]); err == nil && r >= 0 {
+		po.CornerRadius = r
+	} else {
+		return fmt.Errorf("Invalid corner radius: %s", args[0])
+	}
+
+	return nil
+}
+
+func applyMaskOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid mask arguments: %v", args)
+	}
+
+	if m, ok := maskTypes[args[0]]; ok {
+		po.Mask = m
+	} else {
+		return fmt.Errorf("Invalid mask: %s", args[0])
+	}
+
+	return nil
+}
+
+func applyBorderOption(po *processingOptions, args []string) error {
+	if len(args) > 2 {
+		return fmt.Errorf("Invalid border arguments: %v", args)
+	}
+
+	if w, err := strconv.Atoi(args[0]); err == nil && w >= 0 {
+		po.Border.Width = w
+	} else {
+		return fmt.Errorf("Invalid border width: %s", args[0])
+	}
+
+	if len(args) > 1 && len(args[1]) > 0 {
+		if c, err := colorFromHex(args[1]); err == nil {
+			po.Border.Color = c
+		} else {
+			return fmt.Errorf("Invalid border color: %s", args[1])
+		}
+	}
+
+	return nil
+}
+
+func applyShadowOption(po *processingOptions, args []string) error {
+	if len(args) > 5 {
+		return fmt.Errorf("Invalid shadow arguments: %v", args)
+	}
+
+	if len(args) == 1 && args[0] == "0" {
+		po.Shadow.Enabled = false
+		return nil
+	}
+
+	po.Shadow = shadowOptions{Enabled: true, Opacity: 0.5}
+
+	if b, err := strconv.ParseFloat(args[0], 64); err == nil && b >= 0 {
+		po.Shadow.Blur = b
+	} else {
+		return fmt.Errorf("Invalid shadow blur: %s", args[0])
+	}
+
+	if len(args) > 1 && len(args[1]) > 0 {
+		if x, err := strconv.Atoi(args[1]); err == nil {
+			po.Shadow.OffsetX = x
+		} else {
+			return fmt.Errorf("Invalid shadow X offset: %s", args[1])
+		}
+	}
+
+	if len(args) > 2 && len(args[2]) > 0 {
+		if y, err := strconv.Atoi(args[2]); err == nil {
+			po.Shadow.OffsetY = y
+		} else {
+			return fmt.Errorf("Invalid shadow Y offset: %s", args[2])
+		}
+	}
+
+	if len(args) > 3 && len(args[3]) > 0 {
+		if c, err := colorFromHex(args[3]); err == nil {
+			po.Shadow.Color = c
+		} else {
+			return fmt.Errorf("Invalid shadow color: %s", args[3])
+		}
+	}
+
+	if len(args) > 4 && len(args[4]) > 0 {
+		if o, err := strconv.ParseFloat(args[4], 64); err == nil && o >= 0 && o <= 1 {
+			po.Shadow.Opacity = o
+		} else {
+			return fmt.Errorf("Invalid shadow opacity: %s", args[4])
+		}
+	}
+
+	return nil
+}
+
 func applyTrimOption(po *processingOptions, args []string) error {
 	nArgs := len(args)
 
@@ -728,6 +1214,41 @@ func applyBlurOption(po *processingOptions, args []string) error {
 	return nil
 }
 
+func applyBlurAreaOption(po *processingOptions, args []string) error {
+	if len(args) == 1 && args[0] == "0" {
+		po.BlurAreas = nil
+		return nil
+	}
+
+	if len(args) != 5 {
+		return fmt.Errorf("Invalid blur area arguments: %v", args)
+	}
+
+	var area blurAreaOptions
+
+	for i, dst := range []*int{&area.X, &area.Y, &area.Width, &area.Height} {
+		if v, err := strconv.Atoi(args[i]); err == nil && v >= 0 {
+			*dst = v
+		} else {
+			return fmt.Errorf("Invalid blur area: %s", strings.Join(args, ":"))
+		}
+	}
+
+	if area.Width == 0 || area.Height == 0 {
+		return fmt.Errorf("Invalid blur area size: %dx%d", area.Width, area.Height)
+	}
+
+	if b, err := strconv.ParseFloat(args[4], 32); err == nil && b > 0 {
+		area.Sigma = float32(b)
+	} else {
+		return fmt.Errorf("Invalid blur area sigma: %s", args[4])
+	}
+
+	po.BlurAreas = append(po.BlurAreas, area)
+
+	return nil
+}
+
 func applySharpenOption(po *processingOptions, args []string) error {
 	if len(args) > 1 {
 		return fmt.Errorf("Invalid sharpen arguments: %v", args)
@@ -742,6 +1263,20 @@ func applySharpenOption(po *processingOptions, args []string) error {
 	return nil
 }
 
+func applyPixelateOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid pixelate arguments: %v", args)
+	}
+
+	if p, err := strconv.Atoi(args[0]); err == nil && p >= 0 {
+		po.Pixelate = p
+	} else {
+		return fmt.Errorf("Invalid pixelate: %s", args[0])
+	}
+
+	return nil
+}
+
 func applyPresetOption(po *processingOptions, args []string) error {
 	for _, preset := range args {
 		if p, ok := conf.Presets[preset]; ok {
@@ -763,23 +1298,27 @@ func applyPresetOption(po *processingOptions, args []string) error {
 	return nil
 }
 
-func applyWatermarkOption(po *processingOptions, args []string) error {
-	if len(args) > 7 {
-		return fmt.Errorf("Invalid watermark arguments: %v", args)
+func parseWatermarkLayerIndex(arg string) (int, error) {
+	if idx, err := strconv.Atoi(arg); err == nil && idx >= 0 && idx < maxWatermarkLayers {
+		return idx, nil
 	}
 
+	return 0, fmt.Errorf("Invalid watermark layer: %s", arg)
+}
+
+func parseWatermark(wm *watermarkOptions, args []string) error {
 	if o, err := strconv.ParseFloat(args[0], 64); err == nil && o >= 0 && o <= 1 {
-		po.Watermark.Enabled = o > 0
-		po.Watermark.Opacity = o
+		wm.Enabled = o > 0
+		wm.Opacity = o
 	} else {
 		return fmt.Errorf("Invalid watermark opacity: %s", args[0])
 	}
 
 	if len(args) > 1 && len(args[1]) > 0 {
 		if args[1] == "re" {
-			po.Watermark.Replicate = true
+			wm.Replicate = true
 		} else if g, ok := gravityTypes[args[1]]; ok && g != gravityFocusPoint && g != gravitySmart {
-			po.Watermark.Gravity.Type = g
+			wm.Gravity.Type = g
 		} else {
 			return fmt.Errorf("Invalid watermark position: %s", args[1])
 		}
@@ -787,7 +1326,7 @@ func applyWatermarkOption(po *processingOptions, args []string) error {
 
 	if len(args) > 2 && len(args[2]) > 0 {
 		if x, err := strconv.Atoi(args[2]); err == nil {
-			po.Watermark.Gravity.X = float64(x)
+			wm.Gravity.X = float64(x)
 		} else {
 			return fmt.Errorf("Invalid watermark X offset: %s", args[2])
 		}
@@ -795,7 +1334,7 @@ func applyWatermarkOption(po *processingOptions, args []string) error {
 
 	if len(args) > 3 && len(args[3]) > 0 {
 		if y, err := strconv.Atoi(args[3]); err == nil {
-			po.Watermark.Gravity.Y = float64(y)
+			wm.Gravity.Y = float64(y)
 		} else {
 			return fmt.Errorf("Invalid watermark Y offset: %s", args[3])
 		}
@@ -803,7 +1342,7 @@ func applyWatermarkOption(po *processingOptions, args []string) error {
 
 	if len(args) > 4 && len(args[4]) > 0 {
 		if s, err := strconv.ParseFloat(args[4], 64); err == nil && s >= 0 {
-			po.Watermark.Scale = s
+			wm.Scale = s
 		} else {
 			return fmt.Errorf("Invalid watermark scale: %s", args[4])
 		}
@@ -812,6 +1351,118 @@ func applyWatermarkOption(po *processingOptions, args []string) error {
 	return nil
 }
 
+func parseWatermarkText(wm *watermarkOptions, args []string) error {
+	if text, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(args[0], "=")); err == nil && utf8.RuneCount(text) <= maxWatermarkTextLength {
+		wm.Text.Text = string(text)
+	} else {
+		return fmt.Errorf("Invalid watermark text: %s", args[0])
+	}
+
+	if len(args) > 1 && len(args[1]) > 0 {
+		if font, err := url.PathUnescape(args[1]); err == nil {
+			wm.Text.Font = font
+		} else {
+			return fmt.Errorf("Invalid watermark text font: %s", args[1])
+		}
+	}
+
+	if len(args) > 2 && len(args[2]) > 0 {
+		if size, err := strconv.Atoi(args[2]); err == nil && size > 0 && size <= maxWatermarkTextSize {
+			wm.Text.Size = size
+		} else {
+			return fmt.Errorf("Invalid watermark text size: %s", args[2])
+		}
+	}
+
+	if len(args) > 3 && len(args[3]) > 0 {
+		if c, err := colorFromHex(args[3]); err == nil {
+			wm.Text.Color = c
+		} else {
+			return fmt.Errorf("Invalid watermark text color: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func parseWatermarkURL(wm *watermarkOptions, arg string) error {
+	if len(arg) == 0 {
+		wm.URL = ""
+		return nil
+	}
+
+	if wmURL, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(arg, "=")); err == nil {
+		wm.URL = string(wmURL)
+	} else {
+		return fmt.Errorf("Invalid watermark URL: %s", arg)
+	}
+
+	return nil
+}
+
+func applyWatermarkOption(po *processingOptions, args []string) error {
+	if len(args) > 7 {
+		return fmt.Errorf("Invalid watermark arguments: %v", args)
+	}
+
+	return parseWatermark(po.watermarkLayer(0), args)
+}
+
+func applyWatermarkTextOption(po *processingOptions, args []string) error {
+	if len(args) > 4 {
+		return fmt.Errorf("Invalid watermark text arguments: %v", args)
+	}
+
+	return parseWatermarkText(po.watermarkLayer(0), args)
+}
+
+func applyWatermarkURLOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid watermark URL arguments: %v", args)
+	}
+
+	return parseWatermarkURL(po.watermarkLayer(0), args[0])
+}
+
+func applyWatermarkLayerOption(po *processingOptions, args []string) error {
+	if len(args) < 2 || len(args) > 6 {
+		return fmt.Errorf("Invalid watermark layer arguments: %v", args)
+	}
+
+	idx, err := parseWatermarkLayerIndex(args[0])
+	if err != nil {
+		return err
+	}
+
+	return parseWatermark(po.watermarkLayer(idx), args[1:])
+}
+
+func applyWatermarkLayerTextOption(po *processingOptions, args []string) error {
+	if len(args) < 2 || len(args) > 5 {
+		return fmt.Errorf("Invalid watermark layer text arguments: %v", args)
+	}
+
+	idx, err := parseWatermarkLayerIndex(args[0])
+	if err != nil {
+		return err
+	}
+
+	return parseWatermarkText(po.watermarkLayer(idx), args[1:])
+}
+
+func applyWatermarkLayerURLOption(po *processingOptions, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("Invalid watermark layer URL arguments: %v", args)
+	}
+
+	idx, err := parseWatermarkLayerIndex(args[0])
+	if err != nil {
+		return err
+	}
+
+	return parseWatermarkURL(po.watermarkLayer(idx), args[1])
+}
+
 func applyFormatOption(po *processingOptions, args []string) error {
 	if len(args) > 1 {
 		return fmt.Errorf("Invalid format arguments: %v", args)
@@ -830,6 +1481,189 @@ func applyFormatOption(po *processingOptions, args []string) error {
 	return nil
 }
 
+func applyBrightnessOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid brightness arguments: %v", args)
+	}
+
+	if b, err := strconv.Atoi(args[0]); err == nil && b >= -255 && b <= 255 {
+		po.Brightness = b
+	} else {
+		return fmt.Errorf("Invalid brightness: %s", args[0])
+	}
+
+	return nil
+}
+
+func applyContrastOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid contrast arguments: %v", args)
+	}
+
+	if c, err := strconv.ParseFloat(args[0], 64); err == nil && c > 0 {
+		po.Contrast = c
+	} else {
+		return fmt.Errorf("Invalid contrast: %s", args[0])
+	}
+
+	return nil
+}
+
+func applySaturationOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid saturation arguments: %v", args)
+	}
+
+	if s, err := strconv.ParseFloat(args[0], 64); err == nil && s >= 0 {
+		po.Saturation = s
+	} else {
+		return fmt.Errorf("Invalid saturation: %s", args[0])
+	}
+
+	return nil
+}
+
+func applyGammaOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid gamma arguments: %v", args)
+	}
+
+	if g, err := strconv.ParseFloat(args[0], 64); err == nil && g > 0 {
+		po.Gamma = g
+	} else {
+		return fmt.Errorf("Invalid gamma: %s", args[0])
+	}
+
+	return nil
+}
+
+func applyGrayscaleOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid grayscale arguments: %v", args)
+	}
+
+	po.Grayscale = parseBoolOption(args[0])
+
+	return nil
+}
+
+func applySepiaOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid sepia arguments: %v", args)
+	}
+
+	po.Sepia = parseBoolOption(args[0])
+
+	return nil
+}
+
+func applyDuotoneOption(po *processingOptions, args []string) error {
+	if len(args) == 1 && args[0] == "0" {
+		po.Duotone.Enabled = false
+		return nil
+	}
+
+	if len(args) != 2 {
+		return fmt.Errorf("Invalid duotone arguments: %v", args)
+	}
+
+	if c, err := colorFromHex(args[0]); err == nil {
+		po.Duotone.Shadow = c
+	} else {
+		return fmt.Errorf("Invalid duotone shadow color: %s", args[0])
+	}
+
+	if c, err := colorFromHex(args[1]); err == nil {
+		po.Duotone.Highlight = c
+	} else {
+		return fmt.Errorf("Invalid duotone highlight color: %s", args[1])
+	}
+
+	po.Duotone.Enabled = true
+
+	return nil
+}
+
+func applyTintOption(po *processingOptions, args []string) error {
+	if len(args) > 2 {
+		return fmt.Errorf("Invalid tint arguments: %v", args)
+	}
+
+	if len(args) == 1 && args[0] == "0" {
+		po.Tint.Enabled = false
+		return nil
+	}
+
+	if c, err := colorFromHex(args[0]); err == nil {
+		po.Tint.Color = c
+	} else {
+		return fmt.Errorf("Invalid tint color: %s", args[0])
+	}
+
+	po.Tint.Strength = 1
+
+	if len(args) > 1 && len(args[1]) > 0 {
+		if s, err := strconv.ParseFloat(args[1], 64); err == nil && s >= 0 && s <= 1 {
+			po.Tint.Strength = s
+		} else {
+			return fmt.Errorf("Invalid tint strength: %s", args[1])
+		}
+	}
+
+	po.Tint.Enabled = true
+
+	return nil
+}
+
+func applyAutoRotateOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid auto rotate arguments: %v", args)
+	}
+
+	po.AutoRotate = parseBoolOption(args[0])
+
+	return nil
+}
+
+func applyRotateOption(po *processingOptions, args []string) error {
+	if len(args) > 2 {
+		return fmt.Errorf("Invalid rotate arguments: %v", args)
+	}
+
+	if r, err := strconv.ParseFloat(args[0], 64); err == nil && !math.IsInf(r, 0) && !math.IsNaN(r) {
+		po.Rotate.Angle = math.Mod(math.Mod(r, 360)+360, 360)
+	} else {
+		return fmt.Errorf("Invalid rotation angle: %s", args[0])
+	}
+
+	if len(args) > 1 && len(args[1]) > 0 {
+		po.Rotate.Crop = parseBoolOption(args[1])
+	}
+
+	return nil
+}
+
+func applyFlipOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid flip arguments: %v", args)
+	}
+
+	switch args[0] {
+	case "":
+		po.Flip = flipOptions{}
+	case "h":
+		po.Flip = flipOptions{Horizontal: true}
+	case "v":
+		po.Flip = flipOptions{Vertical: true}
+	case "hv", "vh":
+		po.Flip = flipOptions{Horizontal: true, Vertical: true}
+	default:
+		return fmt.Errorf("Invalid flip direction: %s", args[0])
+	}
+
+	return nil
+}
+
 func applyCacheBusterOption(po *processingOptions, args []string) error {
 	if len(args) > 1 {
 		return fmt.Errorf("Invalid cache buster arguments: %v", args)
@@ -840,6 +1674,20 @@ func applyCacheBusterOption(po *processingOptions, args []string) error {
 	return nil
 }
 
+func applyExpiresOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid expires arguments: %v", args)
+	}
+
+	if ts, err := strconv.ParseInt(args[0], 10, 64); err == nil && ts > 0 {
+		po.Expires = ts
+	} else {
+		return fmt.Errorf("Invalid expires: %s", args[0])
+	}
+
+	return nil
+}
+
 func applyFilenameOption(po *processingOptions, args []string) error {
 	if len(args) > 1 {
 		return fmt.Errorf("Invalid filename arguments: %v", args)
@@ -850,6 +1698,39 @@ func applyFilenameOption(po *processingOptions, args []string) error {
 	return nil
 }
 
+func applyPlaceholderOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid placeholder arguments: %v", args)
+	}
+
+	if args[0] == "0" || args[0] == "none" {
+		po.Placeholder = imageTypeUnknown
+		return nil
+	}
+
+	if t, ok := imageTypes[args[0]]; ok && t.IsPlaceholder() {
+		po.Placeholder = t
+	} else {
+		return fmt.Errorf("Invalid placeholder: %s", args[0])
+	}
+
+	return nil
+}
+
+func applyPaletteOption(po *processingOptions, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Invalid palette arguments: %v", args)
+	}
+
+	if n, err := strconv.Atoi(args[0]); err == nil && n >= 0 && n <= paletteMaxColors {
+		po.Palette = n
+	} else {
+		return fmt.Errorf("Invalid palette colors number: %s", args[0])
+	}
+
+	return nil
+}
+
 func applyStripMetadataOption(po *processingOptions, args []string) error {
 	if len(args) > 1 {
 		return fmt.Errorf("Invalid strip metadata arguments: %v", args)
@@ -874,6 +1755,12 @@ func applyProcessingOption(po *processingOptions, name string, args []string) er
 		return applyWidthOption(po, args)
 	case "height", "h":
 		return applyHeightOption(po, args)
+	case "min-width", "mw":
+		return applyMinWidthOption(po, args)
+	case "min-height", "mh":
+		return applyMinHeightOption(po, args)
+	case "zoom", "z":
+		return applyZoomOption(po, args)
 	case "enlarge", "el":
 		return applyEnlargeOption(po, args)
 	case "extend", "ex":
@@ -884,10 +1771,20 @@ func applyProcessingOption(po *processingOptions, name string, args []string) er
 		return applyGravityOption(po, args)
 	case "crop", "c":
 		return applyCropOption(po, args)
+	case "crop_ratio", "cra":
+		return applyCropRatioOption(po, args)
 	case "trim", "t":
 		return applyTrimOption(po, args)
 	case "padding", "pd":
 		return applyPaddingOption(po, args)
+	case "corner_radius", "cr":
+		return applyCornerRadiusOption(po, args)
+	case "mask", "mk":
+		return applyMaskOption(po, args)
+	case "border", "bd":
+		return applyBorderOption(po, args)
+	case "shadow", "shd":
+		return applyShadowOption(po, args)
 	case "quality", "q":
 		return applyQualityOption(po, args)
 	case "max_bytes", "mb":
@@ -896,18 +1793,72 @@ func applyProcessingOption(po *processingOptions, name string, args []string) er
 		return applyBackgroundOption(po, args)
 	case "blur", "bl":
 		return applyBlurOption(po, args)
+	case "blur_area", "ba":
+		return applyBlurAreaOption(po, args)
 	case "sharpen", "sh":
 		return applySharpenOption(po, args)
+	case "pixelate", "pix":
+		return applyPixelateOption(po, args)
+	case "brightness", "br":
+		return applyBrightnessOption(po, args)
+	case "contrast", "co":
+		return applyContrastOption(po, args)
+	case "saturation", "sa":
+		return applySaturationOption(po, args)
+	case "gamma", "ga":
+		return applyGammaOption(po, args)
+	case "grayscale", "gs":
+		return applyGrayscaleOption(po, args)
+	case "sepia", "sp":
+		return applySepiaOption(po, args)
+	case "duotone", "dt":
+		return applyDuotoneOption(po, args)
+	case "tint", "tn":
+		return applyTintOption(po, args)
+	case "auto_rotate", "ar":
+		return applyAutoRotateOption(po, args)
+	case "rotate", "rot":
+		return applyRotateOption(po, args)
+	case "flip", "fl":
+		return applyFlipOption(po, args)
 	case "watermark", "wm":
 		return applyWatermarkOption(po, args)
+	case "watermark_text", "wmt":
+		return applyWatermarkTextOption(po, args)
+	case "watermark_url", "wmu":
+		return applyWatermarkURLOption(po, args)
+	case "watermark_layer", "wml":
+		return applyWatermarkLayerOption(po, args)
+	case "watermark_layer_text", "wmlt":
+		return applyWatermarkLayerTextOption(po, args)
+	case "watermark_layer_url", "wmlu":
+		return applyWatermarkLayerURLOption(po, args)
 	case "preset", "pr":
 		return applyPresetOption(po, args)
 	case "cachebuster", "cb":
 		return applyCacheBusterOption(po, args)
+	case "expires", "exp":
+		return applyExpiresOption(po, args)
 	case "strip_metadata", "sm":
 		return applyStripMetadataOption(po, args)
 	case "filename", "fn":
 		return applyFilenameOption(po, args)
+	case "page", "pg":
+		return applyPageOption(po, args)
+	case "dpi":
+		return applyDpiOption(po, args)
+	case "frames", "fr":
+		return applyFramesOption(po, args)
+	case "frame_step", "fs":
+		return applyFrameStepOption(po, args)
+	case "speed", "spd":
+		return applySpeedOption(po, args)
+	case "loop", "lp":
+		return applyLoopOption(po, args)
+	case "placeholder", "ph":
+		return applyPlaceholderOption(po, args)
+	case "palette", "pl":
+		return applyPaletteOption(po, args)
 	}
 
 	return fmt.Errorf("Unknown processing option: %s", name)
@@ -969,6 +1920,16 @@ func defaultProcessingOptions(headers *processingHeaders) (*processingOptions, e
 		po.EnforceWebP = conf.EnforceWebp
 	}
 
+	if strings.Contains(headers.Accept, "image/avif") {
+		po.PreferAvif = conf.EnableAvifDetection || conf.EnforceAvif
+		po.EnforceAvif = conf.EnforceAvif
+	}
+
+	if strings.Contains(headers.Accept, "image/jxl") {
+		po.PreferJxl = conf.EnableJxlDetection || conf.EnforceJxl
+		po.EnforceJxl = conf.EnforceJxl
+	}
+
 	if conf.EnableClientHints && len(headers.ViewportWidth) > 0 {
 		if vw, err := strconv.Atoi(headers.ViewportWidth); err == nil {
 			po.Width = vw
@@ -1010,6 +1971,8 @@ func parsePathAdvanced(parts []string, headers *processingHeaders) (string, *pro
 		return "", po, err
 	}
 
+	po.SourceURLEncrypted = isEncryptedURL(urlParts)
+
 	if len(extension) > 0 {
 		if err = applyFormatOption(po, []string{extension}); err != nil {
 			return "", po, err
@@ -1037,6 +2000,8 @@ func parsePathPresets(parts []string, headers *processingHeaders) (string, *proc
 		return "", po, err
 	}
 
+	po.SourceURLEncrypted = isEncryptedURL(urlParts)
+
 	if len(extension) > 0 {
 		if err = applyFormatOption(po, []string{extension}); err != nil {
 			return "", po, err
@@ -1079,6 +2044,8 @@ func parsePathBasic(parts []string, headers *processingHeaders) (string, *proces
 		return "", po, err
 	}
 
+	po.SourceURLEncrypted = isEncryptedURL(parts[5:])
+
 	if len(extension) > 0 {
 		if err := applyFormatOption(po, []string{extension}); err != nil {
 			return "", po, err
@@ -1089,6 +2056,10 @@ func parsePathBasic(parts []string, headers *processingHeaders) (string, *proces
 }
 
 func parsePath(ctx context.Context, r *http.Request) (context.Context, error) {
+	return parsePathWithPrefix(ctx, r, "")
+}
+
+func parsePathWithPrefix(ctx context.Context, r *http.Request, prefix string) (context.Context, error) {
 	var err error
 
 	path := trimAfter(r.RequestURI, '?')
@@ -1097,6 +2068,10 @@ func parsePath(ctx context.Context, r *http.Request) (context.Context, error) {
 		path = strings.TrimPrefix(path, conf.PathPrefix)
 	}
 
+	if len(prefix) > 0 {
+		path = strings.TrimPrefix(path, prefix)
+	}
+
 	path = strings.TrimPrefix(path, "/")
 
 	parts := strings.Split(path, "/")
@@ -1133,10 +2108,20 @@ func parsePath(ctx context.Context, r *http.Request) (context.Context, error) {
 		return ctx, newError(404, err.Error(), msgInvalidURL)
 	}
 
+	if po.Expires > 0 && time.Now().Unix() > po.Expires {
+		return ctx, newError(403, "Expired URL", msgExpiredURL)
+	}
+
 	if !isAllowedSource(imageURL) {
 		return ctx, newError(404, "Invalid source", msgInvalidSource)
 	}
 
+	for _, wm := range po.Watermarks {
+		if len(wm.URL) > 0 && !isAllowedSource(wm.URL) {
+			return ctx, newError(404, "Invalid watermark source", msgInvalidSource)
+		}
+	}
+
 	ctx = context.WithValue(ctx, imageURLCtxKey, imageURL)
 	ctx = context.WithValue(ctx, processingOptionsCtxKey, po)
 
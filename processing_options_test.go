@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -29,6 +31,29 @@ func (s *ProcessingOptionsTestSuite) TestParseBase64URL() {
 	assert.Equal(s.T(), imageTypePNG, getProcessingOptions(ctx).Format)
 }
 
+func (s *ProcessingOptionsTestSuite) TestParseInfoPath() {
+	imageURL := "http://images.dev/lorem/ipsum.jpg"
+	req := s.getRequest(fmt.Sprintf("/info/unsafe/plain/%s", imageURL))
+	ctx, err := parsePathWithPrefix(context.Background(), req, infoPathPrefix)
+
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), imageURL, getImageURL(ctx))
+}
+
+func (s *ProcessingOptionsTestSuite) TestParseEncryptedURL() {
+	conf.SourceURLEncryptionKey = securityKey("0123456789abcdef")
+
+	imageURL := "http://images.dev/lorem/ipsum.jpg?param=value"
+	encrypted := base64.RawURLEncoding.EncodeToString(encryptSourceURLForTest(imageURL))
+	req := s.getRequest(fmt.Sprintf("/unsafe/size:100:100/enc/%s.png", encrypted))
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), imageURL, getImageURL(ctx))
+	assert.Equal(s.T(), imageTypePNG, getProcessingOptions(ctx).Format)
+	assert.True(s.T(), getProcessingOptions(ctx).SourceURLEncrypted)
+}
+
 func (s *ProcessingOptionsTestSuite) TestParseBase64URLWithoutExtension() {
 	imageURL := "http://images.dev/lorem/ipsum.jpg?param=value"
 	req := s.getRequest(fmt.Sprintf("/unsafe/size:100:100/%s", base64.RawURLEncoding.EncodeToString([]byte(imageURL))))
@@ -322,6 +347,417 @@ func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedDpr() {
 	po := getProcessingOptions(ctx)
 	assert.Equal(s.T(), 2.0, po.Dpr)
 }
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedGamma() {
+	req := s.getRequest("/unsafe/gamma:2.2/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 2.2, po.Gamma)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedBrightnessInvalid() {
+	req := s.getRequest("/unsafe/brightness:300/plain/http://images.dev/lorem/ipsum.jpg")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedColorEffects() {
+	req := s.getRequest("/unsafe/grayscale:1/sepia:t/duotone:112233:ffeedd/tint:ff0000:0.5/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.True(s.T(), po.Grayscale)
+	assert.True(s.T(), po.Sepia)
+	assert.True(s.T(), po.Duotone.Enabled)
+	assert.Equal(s.T(), rgbColor{0x11, 0x22, 0x33}, po.Duotone.Shadow)
+	assert.Equal(s.T(), rgbColor{0xff, 0xee, 0xdd}, po.Duotone.Highlight)
+	assert.True(s.T(), po.Tint.Enabled)
+	assert.Equal(s.T(), rgbColor{0xff, 0, 0}, po.Tint.Color)
+	assert.Equal(s.T(), 0.5, po.Tint.Strength)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedTintInvalidStrength() {
+	req := s.getRequest("/unsafe/tint:ff0000:2/plain/http://images.dev/lorem/ipsum.jpg")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedPixelate() {
+	req := s.getRequest("/unsafe/pixelate:8/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 8, po.Pixelate)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedBlurArea() {
+	req := s.getRequest("/unsafe/blur_area:10:20:100:50:5/ba:0:0:30:30:2.5/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	require.Len(s.T(), po.BlurAreas, 2)
+	assert.Equal(s.T(), blurAreaOptions{X: 10, Y: 20, Width: 100, Height: 50, Sigma: 5}, po.BlurAreas[0])
+	assert.Equal(s.T(), blurAreaOptions{X: 0, Y: 0, Width: 30, Height: 30, Sigma: 2.5}, po.BlurAreas[1])
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedBlurAreaInvalid() {
+	req := s.getRequest("/unsafe/blur_area:10:20:0:50:5/plain/http://images.dev/lorem/ipsum.jpg")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedCornerRadius() {
+	req := s.getRequest("/unsafe/corner_radius:12/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 12, po.CornerRadius)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedMask() {
+	req := s.getRequest("/unsafe/mask:circle/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), maskCircle, po.Mask)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedMaskInvalid() {
+	req := s.getRequest("/unsafe/mask:star/plain/http://images.dev/lorem/ipsum.jpg")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedBorder() {
+	req := s.getRequest("/unsafe/border:4:ffddcc/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 4, po.Border.Width)
+	assert.Equal(s.T(), rgbColor{0xff, 0xdd, 0xcc}, po.Border.Color)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedShadow() {
+	req := s.getRequest("/unsafe/shadow:8:-2:6:333333:0.3/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.True(s.T(), po.Shadow.Enabled)
+	assert.Equal(s.T(), 8.0, po.Shadow.Blur)
+	assert.Equal(s.T(), -2, po.Shadow.OffsetX)
+	assert.Equal(s.T(), 6, po.Shadow.OffsetY)
+	assert.Equal(s.T(), rgbColor{0x33, 0x33, 0x33}, po.Shadow.Color)
+	assert.Equal(s.T(), 0.3, po.Shadow.Opacity)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedShadowDefaults() {
+	req := s.getRequest("/unsafe/shadow:5/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), shadowOptions{Enabled: true, Blur: 5, Opacity: 0.5}, po.Shadow)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedMinDimensions() {
+	req := s.getRequest("/unsafe/min-width:300/mh:600/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 300, po.MinWidth)
+	assert.Equal(s.T(), 600, po.MinHeight)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedZoom() {
+	req := s.getRequest("/unsafe/zoom:1.5/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 1.5, po.ZoomWidth)
+	assert.Equal(s.T(), 1.5, po.ZoomHeight)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedZoomXY() {
+	req := s.getRequest("/unsafe/zoom:2:0.5/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 2.0, po.ZoomWidth)
+	assert.Equal(s.T(), 0.5, po.ZoomHeight)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedZoomInvalid() {
+	req := s.getRequest("/unsafe/zoom:0/plain/http://images.dev/lorem/ipsum.jpg")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedCrop() {
+	req := s.getRequest("/unsafe/crop:100:200:nowe:10:20/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 100.0, po.Crop.Width)
+	assert.Equal(s.T(), 200.0, po.Crop.Height)
+	assert.Equal(s.T(), gravityNorthWest, po.Crop.Gravity.Type)
+	assert.Equal(s.T(), 10.0, po.Crop.Gravity.X)
+	assert.Equal(s.T(), 20.0, po.Crop.Gravity.Y)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedCropRelative() {
+	req := s.getRequest("/unsafe/crop:0.5:0.25:nowe:0.1:0.2/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 0.5, po.Crop.Width)
+	assert.Equal(s.T(), 0.25, po.Crop.Height)
+	assert.Equal(s.T(), 0.1, po.Crop.Gravity.X)
+	assert.Equal(s.T(), 0.2, po.Crop.Gravity.Y)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedCropInvalidFractional() {
+	req := s.getRequest("/unsafe/crop:100.5:200/plain/http://images.dev/lorem/ipsum.jpg")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedPaddingRelative() {
+	req := s.getRequest("/unsafe/padding:0.1:20/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.True(s.T(), po.Padding.Enabled)
+	assert.Equal(s.T(), 0.1, po.Padding.Top)
+	assert.Equal(s.T(), 20.0, po.Padding.Right)
+	assert.Equal(s.T(), 0.1, po.Padding.Bottom)
+	assert.Equal(s.T(), 20.0, po.Padding.Left)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedCropRatio() {
+	req := s.getRequest("/unsafe/crop_ratio:16:9:fp:0.3:0.4/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.InDelta(s.T(), 16.0/9.0, po.Crop.Ratio, 0.0001)
+	assert.Equal(s.T(), gravityFocusPoint, po.Crop.Gravity.Type)
+	assert.Equal(s.T(), 0.3, po.Crop.Gravity.X)
+	assert.Equal(s.T(), 0.4, po.Crop.Gravity.Y)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedCropRatioInvalid() {
+	req := s.getRequest("/unsafe/crop_ratio:16:0/plain/http://images.dev/lorem/ipsum.jpg")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedPlaceholderFormat() {
+	req := s.getRequest("/unsafe/w:300/plain/http://images.dev/lorem/ipsum.jpg@blurhash")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), imageTypeBLURHASH, po.Format)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedPlaceholder() {
+	req := s.getRequest("/unsafe/placeholder:thumbhash/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), imageTypeTHUMBHASH, po.Placeholder)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedPlaceholderInvalid() {
+	req := s.getRequest("/unsafe/placeholder:png/plain/http://images.dev/lorem/ipsum.jpg")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedPalette() {
+	req := s.getRequest("/unsafe/palette:5/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 5, po.Palette)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedPaletteInvalid() {
+	req := s.getRequest("/unsafe/palette:100/plain/http://images.dev/lorem/ipsum.jpg")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedPage() {
+	req := s.getRequest("/unsafe/page:2/plain/http://images.dev/lorem/ipsum.pdf")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 2, po.Page)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedPageInvalid() {
+	req := s.getRequest("/unsafe/page:-1/plain/http://images.dev/lorem/ipsum.pdf")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedDpi() {
+	req := s.getRequest("/unsafe/dpi:150/plain/http://images.dev/lorem/ipsum.pdf")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 150.0, po.Dpi)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedDpiInvalid() {
+	req := s.getRequest("/unsafe/dpi:0/plain/http://images.dev/lorem/ipsum.pdf")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedAnimation() {
+	req := s.getRequest("/unsafe/frames:10:20/frame_step:2/speed:1.5/loop:3/plain/http://images.dev/lorem/ipsum.gif@webp")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), framesOptions{Start: 10, Count: 20}, po.Frames)
+	assert.Equal(s.T(), 2, po.FrameStep)
+	assert.Equal(s.T(), 1.5, po.Speed)
+	assert.Equal(s.T(), 3, po.Loop)
+	assert.Equal(s.T(), imageTypeWEBP, po.Format)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedFramesStartOnly() {
+	req := s.getRequest("/unsafe/fr:5/plain/http://images.dev/lorem/ipsum.gif")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), framesOptions{Start: 5, Count: 0}, po.Frames)
+	assert.Equal(s.T(), 1, po.FrameStep)
+	assert.Equal(s.T(), 1.0, po.Speed)
+	assert.Equal(s.T(), -1, po.Loop)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedFramesInvalid() {
+	req := s.getRequest("/unsafe/frames:0:-5/plain/http://images.dev/lorem/ipsum.gif")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedSpeedInvalid() {
+	req := s.getRequest("/unsafe/speed:0/plain/http://images.dev/lorem/ipsum.gif")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedRotate() {
+	req := s.getRequest("/unsafe/rotate:-90/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 270.0, po.Rotate.Angle)
+	assert.False(s.T(), po.Rotate.Crop)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedRotateArbitraryAngle() {
+	req := s.getRequest("/unsafe/rotate:372.5:1/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), 12.5, po.Rotate.Angle)
+	assert.True(s.T(), po.Rotate.Crop)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedRotateInvalid() {
+	req := s.getRequest("/unsafe/rotate:abc/plain/http://images.dev/lorem/ipsum.jpg")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedFlip() {
+	req := s.getRequest("/unsafe/flip:hv/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.True(s.T(), po.Flip.Horizontal)
+	assert.True(s.T(), po.Flip.Vertical)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedAutoRotate() {
+	req := s.getRequest("/unsafe/auto_rotate:0/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.False(s.T(), po.AutoRotate)
+}
+
 func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedWatermark() {
 	req := s.getRequest("/unsafe/watermark:0.5:soea:10:20:0.6/plain/http://images.dev/lorem/ipsum.jpg")
 	ctx, err := parsePath(context.Background(), req)
@@ -329,11 +765,122 @@ func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedWatermark() {
 	require.Nil(s.T(), err)
 
 	po := getProcessingOptions(ctx)
-	assert.True(s.T(), po.Watermark.Enabled)
-	assert.Equal(s.T(), gravitySouthEast, po.Watermark.Gravity.Type)
-	assert.Equal(s.T(), 10.0, po.Watermark.Gravity.X)
-	assert.Equal(s.T(), 20.0, po.Watermark.Gravity.Y)
-	assert.Equal(s.T(), 0.6, po.Watermark.Scale)
+	require.Len(s.T(), po.Watermarks, 1)
+	assert.True(s.T(), po.Watermarks[0].Enabled)
+	assert.Equal(s.T(), gravitySouthEast, po.Watermarks[0].Gravity.Type)
+	assert.Equal(s.T(), 10.0, po.Watermarks[0].Gravity.X)
+	assert.Equal(s.T(), 20.0, po.Watermarks[0].Gravity.Y)
+	assert.Equal(s.T(), 0.6, po.Watermarks[0].Scale)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedWatermarkText() {
+	text := base64.RawURLEncoding.EncodeToString([]byte("Hello, мир!"))
+	req := s.getRequest(fmt.Sprintf("/unsafe/wm:1/wmt:%s:DejaVu%%20Sans:32:f00/plain/http://images.dev/lorem/ipsum.jpg", text))
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	require.Len(s.T(), po.Watermarks, 1)
+	assert.True(s.T(), po.Watermarks[0].Enabled)
+	assert.Equal(s.T(), "Hello, мир!", po.Watermarks[0].Text.Text)
+	assert.Equal(s.T(), "DejaVu Sans", po.Watermarks[0].Text.Font)
+	assert.Equal(s.T(), 32, po.Watermarks[0].Text.Size)
+	assert.Equal(s.T(), rgbColor{255, 0, 0}, po.Watermarks[0].Text.Color)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedWatermarkTextInvalidSize() {
+	text := base64.RawURLEncoding.EncodeToString([]byte("Hello"))
+	req := s.getRequest(fmt.Sprintf("/unsafe/wm:1/wmt:%s::10000/plain/http://images.dev/lorem/ipsum.jpg", text))
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedWatermarkTextTooLong() {
+	text := base64.RawURLEncoding.EncodeToString([]byte(strings.Repeat("a", maxWatermarkTextLength+1)))
+	req := s.getRequest(fmt.Sprintf("/unsafe/wm:1/wmt:%s/plain/http://images.dev/lorem/ipsum.jpg", text))
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedWatermarkURL() {
+	wmURL := "http://images.dev/watermark.png"
+	req := s.getRequest(fmt.Sprintf("/unsafe/wm:1/wmu:%s/plain/http://images.dev/lorem/ipsum.jpg", base64.RawURLEncoding.EncodeToString([]byte(wmURL))))
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	require.Len(s.T(), po.Watermarks, 1)
+	assert.Equal(s.T(), wmURL, po.Watermarks[0].URL)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedWatermarkURLNotAllowed() {
+	conf.AllowedSources = []string{"http://images.dev/"}
+
+	wmURL := "http://evil.dev/watermark.png"
+	req := s.getRequest(fmt.Sprintf("/unsafe/wm:1/wmu:%s/plain/http://images.dev/lorem/ipsum.jpg", base64.RawURLEncoding.EncodeToString([]byte(wmURL))))
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), 404, err.(*imgproxyError).StatusCode)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedMultipleWatermarks() {
+	wmURL := "http://images.dev/watermark.png"
+	req := s.getRequest(fmt.Sprintf(
+		"/unsafe/wmu:%s/wm:1:soea:10:10:0.2/wml:1:0.3:re/wmlt:1:%s/plain/http://images.dev/lorem/ipsum.jpg",
+		base64.RawURLEncoding.EncodeToString([]byte(wmURL)),
+		base64.RawURLEncoding.EncodeToString([]byte("Hello")),
+	))
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	require.Len(s.T(), po.Watermarks, 2)
+
+	assert.True(s.T(), po.Watermarks[0].Enabled)
+	assert.Equal(s.T(), wmURL, po.Watermarks[0].URL)
+	assert.Equal(s.T(), gravitySouthEast, po.Watermarks[0].Gravity.Type)
+	assert.Equal(s.T(), 0.2, po.Watermarks[0].Scale)
+
+	assert.True(s.T(), po.Watermarks[1].Enabled)
+	assert.Empty(s.T(), po.Watermarks[1].URL)
+	assert.Equal(s.T(), "Hello", po.Watermarks[1].Text.Text)
+	assert.True(s.T(), po.Watermarks[1].Replicate)
+	assert.Equal(s.T(), 0.3, po.Watermarks[1].Opacity)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedWatermarkOverride() {
+	conf.Presets["test1"] = urlOptions{
+		urlOption{Name: "watermark", Args: []string{"1", "soea", "10", "10"}},
+		urlOption{Name: "watermark_layer", Args: []string{"1", "0.3", "re"}},
+	}
+
+	req := s.getRequest("/unsafe/preset:test1/wm:0.5:nowe/wml:1:0/plain/http://images.dev/lorem/ipsum.jpg")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	require.Len(s.T(), po.Watermarks, 2)
+
+	assert.True(s.T(), po.Watermarks[0].Enabled)
+	assert.Equal(s.T(), 0.5, po.Watermarks[0].Opacity)
+	assert.Equal(s.T(), gravityNorthWest, po.Watermarks[0].Gravity.Type)
+	assert.Equal(s.T(), 10.0, po.Watermarks[0].Gravity.X)
+
+	assert.False(s.T(), po.Watermarks[1].Enabled)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedWatermarkLayerInvalid() {
+	req := s.getRequest("/unsafe/wml:100:1/plain/http://images.dev/lorem/ipsum.jpg")
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
 }
 
 func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedPreset() {
@@ -404,6 +951,26 @@ func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedCachebuster() {
 	assert.Equal(s.T(), "123", po.CacheBuster)
 }
 
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedExpires() {
+	expires := time.Now().Add(time.Hour).Unix()
+	req := s.getRequest(fmt.Sprintf("/unsafe/exp:%d/plain/http://images.dev/lorem/ipsum.jpg", expires))
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), expires, po.Expires)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedExpired() {
+	expires := time.Now().Add(-time.Hour).Unix()
+	req := s.getRequest(fmt.Sprintf("/unsafe/exp:%d/plain/http://images.dev/lorem/ipsum.jpg", expires))
+	_, err := parsePath(context.Background(), req)
+
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), 403, err.(*imgproxyError).StatusCode)
+}
+
 func (s *ProcessingOptionsTestSuite) TestParsePathAdvancedStripMetadata() {
 	req := s.getRequest("/unsafe/strip_metadata:true/plain/http://images.dev/lorem/ipsum.jpg")
 	ctx, err := parsePath(context.Background(), req)
@@ -442,6 +1009,62 @@ func (s *ProcessingOptionsTestSuite) TestParsePathWebpEnforce() {
 	assert.Equal(s.T(), true, po.EnforceWebP)
 }
 
+func (s *ProcessingOptionsTestSuite) TestParsePathAvifDetection() {
+	conf.EnableAvifDetection = true
+
+	req := s.getRequest("/unsafe/plain/http://images.dev/lorem/ipsum.jpg")
+	req.Header.Set("Accept", "image/avif")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), true, po.PreferAvif)
+	assert.Equal(s.T(), false, po.EnforceAvif)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathAvifEnforce() {
+	conf.EnforceAvif = true
+
+	req := s.getRequest("/unsafe/plain/http://images.dev/lorem/ipsum.jpg@png")
+	req.Header.Set("Accept", "image/avif")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), true, po.PreferAvif)
+	assert.Equal(s.T(), true, po.EnforceAvif)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathJxlDetection() {
+	conf.EnableJxlDetection = true
+
+	req := s.getRequest("/unsafe/plain/http://images.dev/lorem/ipsum.jpg")
+	req.Header.Set("Accept", "image/jxl")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), true, po.PreferJxl)
+	assert.Equal(s.T(), false, po.EnforceJxl)
+}
+
+func (s *ProcessingOptionsTestSuite) TestParsePathJxlEnforce() {
+	conf.EnforceJxl = true
+
+	req := s.getRequest("/unsafe/plain/http://images.dev/lorem/ipsum.jpg@png")
+	req.Header.Set("Accept", "image/jxl")
+	ctx, err := parsePath(context.Background(), req)
+
+	require.Nil(s.T(), err)
+
+	po := getProcessingOptions(ctx)
+	assert.Equal(s.T(), true, po.PreferJxl)
+	assert.Equal(s.T(), true, po.EnforceJxl)
+}
+
 func (s *ProcessingOptionsTestSuite) TestParsePathWidthHeader() {
 	conf.EnableClientHints = true
 
@@ -13,17 +13,20 @@ import (
 var (
 	prometheusEnabled = false
 
-	prometheusRequestsTotal      prometheus.Counter
-	prometheusErrorsTotal        *prometheus.CounterVec
-	prometheusRequestDuration    prometheus.Histogram
-	prometheusDownloadDuration   prometheus.Histogram
-	prometheusProcessingDuration prometheus.Histogram
-	prometheusBufferSize         *prometheus.HistogramVec
-	prometheusBufferDefaultSize  *prometheus.GaugeVec
-	prometheusBufferMaxSize      *prometheus.GaugeVec
-	prometheusVipsMemory         prometheus.GaugeFunc
-	prometheusVipsMaxMemory      prometheus.GaugeFunc
-	prometheusVipsAllocs         prometheus.GaugeFunc
+	prometheusRequestsTotal        prometheus.Counter
+	prometheusErrorsTotal          *prometheus.CounterVec
+	prometheusRequestDuration      prometheus.Histogram
+	prometheusDownloadDuration     prometheus.Histogram
+	prometheusProcessingDuration   prometheus.Histogram
+	prometheusBufferSize           *prometheus.HistogramVec
+	prometheusBufferDefaultSize    *prometheus.GaugeVec
+	prometheusBufferMaxSize        *prometheus.GaugeVec
+	prometheusVipsMemory           prometheus.GaugeFunc
+	prometheusVipsMaxMemory        prometheus.GaugeFunc
+	prometheusVipsAllocs           prometheus.GaugeFunc
+	prometheusResultCacheHits      *prometheus.CounterVec
+	prometheusResultCacheMisses    prometheus.Counter
+	prometheusResultCacheEvictions *prometheus.CounterVec
 )
 
 func initPrometheus() {
@@ -97,6 +100,24 @@ func initPrometheus() {
 		Help:      "A gauge of the number of active vips allocations.",
 	}, vipsGetAllocs)
 
+	prometheusResultCacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: conf.PrometheusNamespace,
+		Name:      "result_cache_hits_total",
+		Help:      "A counter of the result cache hits separated by tier.",
+	}, []string{"tier"})
+
+	prometheusResultCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: conf.PrometheusNamespace,
+		Name:      "result_cache_misses_total",
+		Help:      "A counter of the result cache misses.",
+	})
+
+	prometheusResultCacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: conf.PrometheusNamespace,
+		Name:      "result_cache_evictions_total",
+		Help:      "A counter of the result cache evictions separated by tier.",
+	}, []string{"tier"})
+
 	prometheus.MustRegister(
 		prometheusRequestsTotal,
 		prometheusErrorsTotal,
@@ -109,6 +130,9 @@ func initPrometheus() {
 		prometheusVipsMemory,
 		prometheusVipsMaxMemory,
 		prometheusVipsAllocs,
+		prometheusResultCacheHits,
+		prometheusResultCacheMisses,
+		prometheusResultCacheEvictions,
 	)
 
 	prometheusEnabled = true
@@ -155,3 +179,11 @@ func setPrometheusBufferDefaultSize(t string, size int) {
 func setPrometheusBufferMaxSize(t string, size int) {
 	prometheusBufferMaxSize.With(prometheus.Labels{"type": t}).Set(float64(size))
 }
+
+func incrementPrometheusResultCacheHits(tier string) {
+	prometheusResultCacheHits.With(prometheus.Labels{"tier": tier}).Inc()
+}
+
+func incrementPrometheusResultCacheEvictions(tier string) {
+	prometheusResultCacheEvictions.With(prometheus.Labels{"tier": tier}).Inc()
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var resultCache *resultCacheStore
+
+type resultCacheEntry struct {
+	Data          []byte
+	Format        imageType
+	DominantColor string
+	CacheControl  string
+	Expires       string
+	ETag          string
+	CreatedAt     time.Time
+}
+
+type resultCacheItem struct {
+	key       string
+	size      int
+	createdAt time.Time
+	entry     *resultCacheEntry
+}
+
+type memoryResultCache struct {
+	maxSize int
+	size    int
+	items   map[string]*list.Element
+	lru     *list.List
+
+	mutex sync.Mutex
+}
+
+type diskResultCache struct {
+	dir     string
+	maxSize int
+	size    int
+	items   map[string]*list.Element
+	lru     *list.List
+
+	mutex sync.Mutex
+}
+
+type resultCacheStore struct {
+	ttl    time.Duration
+	memory *memoryResultCache
+	disk   *diskResultCache
+}
+
+func initResultCache() error {
+	if !conf.ResultCacheEnabled {
+		return nil
+	}
+
+	rc := &resultCacheStore{
+		ttl: time.Duration(conf.ResultCacheTTL) * time.Second,
+	}
+
+	if conf.ResultCacheMemorySize > 0 {
+		rc.memory = newMemoryResultCache(conf.ResultCacheMemorySize)
+	}
+
+	if len(conf.ResultCacheDiskPath) > 0 && conf.ResultCacheDiskSize > 0 {
+		disk, err := newDiskResultCache(conf.ResultCacheDiskPath, conf.ResultCacheDiskSize, rc.ttl)
+		if err != nil {
+			return fmt.Errorf("Can't initialize result cache: %s", err)
+		}
+		rc.disk = disk
+	}
+
+	resultCache = rc
+
+	return nil
+}
+
+func resultCacheKey(r *http.Request, po *processingOptions) string {
+	h := sha256.New()
+	h.Write([]byte(trimAfter(r.RequestURI, '?')))
+	h.Write([]byte{0})
+	h.Write([]byte(po.String()))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *resultCacheStore) expired(createdAt time.Time) bool {
+	return time.Since(createdAt) > c.ttl
+}
+
+func (c *resultCacheStore) Get(key string) (*resultCacheEntry, bool) {
+	if c.memory != nil {
+		if entry, ok := c.memory.Get(key); ok {
+			if !c.expired(entry.CreatedAt) {
+				if prometheusEnabled {
+					incrementPrometheusResultCacheHits("memory")
+				}
+				return entry, true
+			}
+			c.memory.Remove(key)
+		}
+	}
+
+	if c.disk != nil {
+		if entry, ok := c.disk.Get(key); ok {
+			if !c.expired(entry.CreatedAt) {
+				if prometheusEnabled {
+					incrementPrometheusResultCacheHits("disk")
+				}
+				if c.memory != nil {
+					c.setMemory(key, entry)
+				}
+				return entry, true
+			}
+			c.disk.Remove(key)
+		}
+	}
+
+	if prometheusEnabled {
+		prometheusResultCacheMisses.Inc()
+	}
+
+	return nil, false
+}
+
+func (c *resultCacheStore) Set(key string, entry *resultCacheEntry) {
+	if c.memory != nil {
+		c.setMemory(key, entry)
+		return
+	}
+
+	if c.disk != nil {
+		if err := c.disk.Set(key, entry); err != nil {
+			logWarning("Can't write result cache entry: %s", err)
+		}
+	}
+}
+
+func (c *resultCacheStore) setMemory(key string, entry *resultCacheEntry) {
+	evicted := c.memory.Set(key, entry)
+
+	if c.disk == nil {
+		return
+	}
+
+	// Spill entries evicted from memory to disk
+	for _, item := range evicted {
+		if c.expired(item.createdAt) {
+			continue
+		}
+		if err := c.disk.Set(item.key, item.entry); err != nil {
+			logWarning("Can't write result cache entry: %s", err)
+		}
+	}
+}
+
+func newMemoryResultCache(maxSize int) *memoryResultCache {
+	return &memoryResultCache{
+		maxSize: maxSize,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *memoryResultCache) Get(key string) (*resultCacheEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.lru.MoveToFront(el)
+		return el.Value.(*resultCacheItem).entry, true
+	}
+
+	return nil, false
+}
+
+func (c *memoryResultCache) Set(key string, entry *resultCacheEntry) []*resultCacheItem {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	size := len(entry.Data)
+
+	if size > c.maxSize {
+		return []*resultCacheItem{{key: key, size: size, createdAt: entry.CreatedAt, entry: entry}}
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+
+	c.items[key] = c.lru.PushFront(&resultCacheItem{
+		key:       key,
+		size:      size,
+		createdAt: entry.CreatedAt,
+		entry:     entry,
+	})
+	c.size += size
+
+	var evicted []*resultCacheItem
+
+	for c.size > c.maxSize {
+		item := c.removeElement(c.lru.Back())
+		evicted = append(evicted, item)
+
+		if prometheusEnabled {
+			incrementPrometheusResultCacheEvictions("memory")
+		}
+	}
+
+	return evicted
+}
+
+func (c *memoryResultCache) Remove(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *memoryResultCache) removeElement(el *list.Element) *resultCacheItem {
+	item := c.lru.Remove(el).(*resultCacheItem)
+	delete(c.items, item.key)
+	c.size -= item.size
+	return item
+}
+
+func newDiskResultCache(dir string, maxSize int, ttl time.Duration) (*diskResultCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	c := &diskResultCache{
+		dir:     dir,
+		maxSize: maxSize,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+
+	// Restore the index from the files left by the previous run
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	for _, f := range files {
+		if !f.Mode().IsRegular() || !isResultCacheKey(f.Name()) {
+			continue
+		}
+
+		if time.Since(f.ModTime()) > ttl {
+			os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+
+		c.items[f.Name()] = c.lru.PushFront(&resultCacheItem{
+			key:       f.Name(),
+			size:      int(f.Size()),
+			createdAt: f.ModTime(),
+		})
+		c.size += int(f.Size())
+	}
+
+	// Trim the restored index to the size limit. This isn't a runtime
+	// eviction, so we don't count it in metrics
+	c.mutex.Lock()
+	for c.size > c.maxSize {
+		c.removeElement(c.lru.Back())
+	}
+	c.mutex.Unlock()
+
+	return c, nil
+}
+
+func isResultCacheKey(name string) bool {
+	if len(name) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+func (c *diskResultCache) path(key string) string {
+	return filepath.Join(c.dir, key)
+}
+
+func (c *diskResultCache) Get(key string) (*resultCacheEntry, bool) {
+	c.mutex.Lock()
+	el, ok := c.items[key]
+	if ok {
+		c.lru.MoveToFront(el)
+	}
+	c.mutex.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	data, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		c.Remove(key)
+		return nil, false
+	}
+
+	entry := new(resultCacheEntry)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(entry); err != nil {
+		logWarning("Can't read result cache entry: %s", err)
+		c.Remove(key)
+		return nil, false
+	}
+
+	return entry, true
+}
+
+func (c *diskResultCache) Set(key string, entry *resultCacheEntry) error {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(entry); err != nil {
+		return err
+	}
+
+	size := buf.Len()
+	if size > c.maxSize {
+		return nil
+	}
+
+	tmp, err := ioutil.TempFile(c.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(buf.Bytes())
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		item := c.lru.Remove(el).(*resultCacheItem)
+		delete(c.items, key)
+		c.size -= item.size
+	}
+
+	c.items[key] = c.lru.PushFront(&resultCacheItem{
+		key:       key,
+		size:      size,
+		createdAt: entry.CreatedAt,
+	})
+	c.size += size
+
+	c.evict()
+
+	return nil
+}
+
+func (c *diskResultCache) Remove(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *diskResultCache) evict() {
+	for c.size > c.maxSize {
+		c.removeElement(c.lru.Back())
+
+		if prometheusEnabled {
+			incrementPrometheusResultCacheEvictions("disk")
+		}
+	}
+}
+
+func (c *diskResultCache) removeElement(el *list.Element) {
+	item := c.lru.Remove(el).(*resultCacheItem)
+	delete(c.items, item.key)
+	c.size -= item.size
+	os.Remove(c.path(item.key))
+}
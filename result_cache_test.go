@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ResultCacheTestSuite struct {
+	MainTestSuite
+
+	dir string
+}
+
+func (s *ResultCacheTestSuite) SetupTest() {
+	s.MainTestSuite.SetupTest()
+
+	dir, err := ioutil.TempDir("", "imgproxy-result-cache")
+	require.Nil(s.T(), err)
+
+	s.dir = dir
+}
+
+func (s *ResultCacheTestSuite) TearDownTest() {
+	os.RemoveAll(s.dir)
+
+	s.MainTestSuite.TearDownTest()
+}
+
+func (s *ResultCacheTestSuite) entry(size int) *resultCacheEntry {
+	return &resultCacheEntry{
+		Data:      make([]byte, size),
+		Format:    imageTypePNG,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (s *ResultCacheTestSuite) TestMemoryEviction() {
+	rc := &resultCacheStore{ttl: time.Hour, memory: newMemoryResultCache(10)}
+
+	rc.Set("a", s.entry(4))
+	rc.Set("b", s.entry(4))
+
+	// Touch "a" so "b" becomes the least recently used
+	_, ok := rc.Get("a")
+	require.True(s.T(), ok)
+
+	rc.Set("c", s.entry(4))
+
+	_, ok = rc.Get("a")
+	assert.True(s.T(), ok)
+
+	_, ok = rc.Get("b")
+	assert.False(s.T(), ok)
+
+	_, ok = rc.Get("c")
+	assert.True(s.T(), ok)
+}
+
+func (s *ResultCacheTestSuite) TestSpillToDisk() {
+	disk, err := newDiskResultCache(s.dir, 1024*1024, time.Hour)
+	require.Nil(s.T(), err)
+
+	rc := &resultCacheStore{ttl: time.Hour, memory: newMemoryResultCache(10), disk: disk}
+
+	key := resultCacheKeyForTest("a")
+
+	rc.Set(key, s.entry(8))
+	rc.Set(resultCacheKeyForTest("b"), s.entry(8))
+
+	entry, ok := rc.Get(key)
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), imageTypePNG, entry.Format)
+	assert.Len(s.T(), entry.Data, 8)
+}
+
+func (s *ResultCacheTestSuite) TestTTL() {
+	rc := &resultCacheStore{ttl: time.Minute, memory: newMemoryResultCache(10)}
+
+	entry := s.entry(4)
+	entry.CreatedAt = time.Now().Add(-time.Hour)
+
+	rc.Set("a", entry)
+
+	_, ok := rc.Get("a")
+	assert.False(s.T(), ok)
+}
+
+func (s *ResultCacheTestSuite) TestDiskIndexRestore() {
+	disk, err := newDiskResultCache(s.dir, 1024*1024, time.Hour)
+	require.Nil(s.T(), err)
+
+	key := resultCacheKeyForTest("a")
+	require.Nil(s.T(), disk.Set(key, s.entry(8)))
+
+	disk, err = newDiskResultCache(s.dir, 1024*1024, time.Hour)
+	require.Nil(s.T(), err)
+
+	_, ok := disk.Get(key)
+	assert.True(s.T(), ok)
+}
+
+func resultCacheKeyForTest(name string) string {
+	return resultCacheKey(
+		&http.Request{RequestURI: "/unsafe/plain/http://images.dev/" + name},
+		newProcessingOptions(),
+	)
+}
+
+func TestResultCache(t *testing.T) {
+	suite.Run(t, new(ResultCacheTestSuite))
+}
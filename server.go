@@ -24,6 +24,7 @@ func buildRouter() *router {
 	r.GET("/", handleLanding, true)
 	r.GET("/health", handleHealth, true)
 	r.GET("/favicon.ico", handleFavicon, true)
+	r.GET(infoPathPrefix+"/", withCORS(withSecret(handleInfo)), false)
 	r.GET("/", withCORS(withSecret(handleProcessing)), false)
 	r.HEAD("/", withCORS(handleHead), false)
 	r.OPTIONS("/", withCORS(handleHead), false)
@@ -79,6 +80,10 @@ func withCORS(h routeHandler) routeHandler {
 		if len(conf.AllowOrigin) > 0 {
 			rw.Header().Set("Access-Control-Allow-Origin", conf.AllowOrigin)
 			rw.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+
+			if conf.DominantColorHeader {
+				rw.Header().Set("Access-Control-Expose-Headers", dominantColorHeader)
+			}
 		}
 
 		h(reqID, rw, r)
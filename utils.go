@@ -20,6 +20,13 @@ func minInt(a, b int) int {
 	return b
 }
 
+func absInt(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
 func minNonZeroInt(a, b int) int {
 	switch {
 	case a == 0:
@@ -13,6 +13,9 @@
 #define VIPS_SUPPORT_SVG \
   (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 3))
 
+#define VIPS_SUPPORT_PDF \
+  (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 5))
+
 #define VIPS_SUPPORT_TIFF \
   (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 6))
 
@@ -31,6 +34,18 @@
 #define VIPS_SUPPORT_HEIF \
   (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 8))
 
+#define VIPS_SUPPORT_AVIF \
+  (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 9))
+
+#define VIPS_SUPPORT_AVIF_SPEED \
+  (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 10))
+
+#define VIPS_SUPPORT_JXL \
+  (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 11))
+
+#define VIPS_SUPPORT_ARRAY_HEADERS \
+  (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 9))
+
 #define VIPS_SUPPORT_BUILTIN_ICC \
   (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 8))
 
@@ -40,6 +55,9 @@
 #define VIPS_SUPPORT_FIND_TRIM \
   (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 6))
 
+#define VIPS_SUPPORT_ROTATE_BACKGROUND \
+  (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 7))
+
 #define EXIF_ORIENTATION "exif-ifd0-Orientation"
 
 #if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 8))
@@ -89,6 +107,12 @@ vips_type_find_load_go(int imgtype) {
     return vips_type_find("VipsOperation", "magickload_buffer");
   case (TIFF):
     return vips_type_find("VipsOperation", "tiffload_buffer");
+  case (AVIF):
+    return vips_type_find("VipsOperation", "heifload_buffer");
+  case (JXL):
+    return vips_type_find("VipsOperation", "jxlload_buffer");
+  case (PDF):
+    return vips_type_find("VipsOperation", "pdfload_buffer");
   }
   return 0;
 }
@@ -111,6 +135,14 @@ vips_type_find_save_go(int imgtype) {
     return vips_type_find("VipsOperation", "magicksave_buffer");
   case (TIFF):
     return vips_type_find("VipsOperation", "tiffsave_buffer");
+  case (AVIF):
+#if VIPS_SUPPORT_AVIF
+    return vips_type_find("VipsOperation", "heifsave_buffer");
+#else
+    return 0;
+#endif
+  case (JXL):
+    return vips_type_find("VipsOperation", "jxlsave_buffer");
   }
 
   return 0;
@@ -130,7 +162,7 @@ vips_pngload_go(void *buf, size_t len, VipsImage **out) {
 }
 
 int
-vips_webpload_go(void *buf, size_t len, double scale, int pages, VipsImage **out) {
+vips_webpload_go(void *buf, size_t len, double scale, int page, int pages, VipsImage **out) {
   return vips_webpload_buffer(
     buf, len, out,
     "access", VIPS_ACCESS_SEQUENTIAL,
@@ -140,6 +172,7 @@ vips_webpload_go(void *buf, size_t len, double scale, int pages, VipsImage **out
     "shrink", (int)(1.0 / scale),
 #endif
 #if VIPS_SUPPORT_WEBP_ANIMATION
+    "page", page,
     "n", pages,
 #endif
     NULL
@@ -147,9 +180,9 @@ vips_webpload_go(void *buf, size_t len, double scale, int pages, VipsImage **out
 }
 
 int
-vips_gifload_go(void *buf, size_t len, int pages, VipsImage **out) {
+vips_gifload_go(void *buf, size_t len, int page, int pages, VipsImage **out) {
   #if VIPS_SUPPORT_GIF
-    return vips_gifload_buffer(buf, len, out, "access", VIPS_ACCESS_SEQUENTIAL, "n", pages, NULL);
+    return vips_gifload_buffer(buf, len, out, "access", VIPS_ACCESS_SEQUENTIAL, "page", page, "n", pages, NULL);
   #else
     vips_error("vips_gifload_go", "Loading GIF is not supported (libvips 8.3+ reuired)");
     return 1;
@@ -167,15 +200,35 @@ vips_svgload_go(void *buf, size_t len, double scale, VipsImage **out) {
 }
 
 int
-vips_heifload_go(void *buf, size_t len, VipsImage **out) {
+vips_pdfload_go(void *buf, size_t len, int page, double scale, VipsImage **out) {
+#if VIPS_SUPPORT_PDF
+  return vips_pdfload_buffer(buf, len, out, "access", VIPS_ACCESS_SEQUENTIAL, "page", page, "scale", scale, NULL);
+#else
+  vips_error("vips_pdfload_go", "Loading PDF is not supported (libvips 8.5+ reuired)");
+  return 1;
+#endif
+}
+
+int
+vips_heifload_go(void *buf, size_t len, int page, VipsImage **out) {
 #if VIPS_SUPPORT_HEIF
-  return vips_heifload_buffer(buf, len, out, "access", VIPS_ACCESS_SEQUENTIAL, NULL);
+  return vips_heifload_buffer(buf, len, out, "access", VIPS_ACCESS_SEQUENTIAL, "page", page, NULL);
 #else
   vips_error("vips_heifload_go", "Loading HEIF is not supported (libvips 8.8+ reuired)");
   return 1;
 #endif
 }
 
+int
+vips_jxlload_go(void *buf, size_t len, VipsImage **out) {
+#if VIPS_SUPPORT_JXL
+  return vips_jxlload_buffer(buf, len, out, "access", VIPS_ACCESS_SEQUENTIAL, NULL);
+#else
+  vips_error("vips_jxlload_go", "Loading JPEG XL is not supported (libvips 8.11+ reuired)");
+  return 1;
+#endif
+}
+
 int
 vips_bmpload_go(void *buf, size_t len, VipsImage **out) {
 #if VIPS_SUPPORT_MAGICK
@@ -187,9 +240,9 @@ vips_bmpload_go(void *buf, size_t len, VipsImage **out) {
 }
 
 int
-vips_tiffload_go(void *buf, size_t len, VipsImage **out) {
+vips_tiffload_go(void *buf, size_t len, int page, VipsImage **out) {
 #if VIPS_SUPPORT_TIFF
-  return vips_tiffload_buffer(buf, len, out, "access", VIPS_ACCESS_SEQUENTIAL, NULL);
+  return vips_tiffload_buffer(buf, len, out, "access", VIPS_ACCESS_SEQUENTIAL, "page", page, NULL);
 #else
   vips_error("vips_tiffload_go", "Loading TIFF is not supported (libvips 8.6+ reuired)");
   return 1;
@@ -239,6 +292,23 @@ vips_is_animated(VipsImage * in) {
           vips_image_get_typeof(in, "gif-loop") != G_TYPE_INVALID );
 }
 
+int
+vips_image_get_array_int_go(VipsImage *image, const char *name, int **out, int *n) {
+#if VIPS_SUPPORT_ARRAY_HEADERS
+  return vips_image_get_array_int(image, name, out, n);
+#else
+  vips_error("vips_image_get_array_int_go", "Array headers are not supported (libvips 8.9+ reuired)");
+  return 1;
+#endif
+}
+
+void
+vips_image_set_array_int_go(VipsImage *image, const char *name, const int *array, int n) {
+#if VIPS_SUPPORT_ARRAY_HEADERS
+  vips_image_set_array_int(image, name, array, n);
+#endif
+}
+
 gboolean
 vips_image_hasalpha_go(VipsImage * in) {
 #if VIPS_SUPPORT_HASALPHA
@@ -271,12 +341,12 @@ vips_rad2float_go(VipsImage *in, VipsImage **out) {
 }
 
 int
-vips_resize_go(VipsImage *in, VipsImage **out, double scale) {
-  return vips_resize(in, out, scale, NULL);
+vips_resize_go(VipsImage *in, VipsImage **out, double wscale, double hscale) {
+  return vips_resize(in, out, wscale, "vscale", hscale, NULL);
 }
 
 int
-vips_resize_with_premultiply(VipsImage *in, VipsImage **out, double scale) {
+vips_resize_with_premultiply(VipsImage *in, VipsImage **out, double wscale, double hscale) {
 	VipsBandFormat format;
   VipsImage *tmp1, *tmp2;
 
@@ -285,7 +355,7 @@ vips_resize_with_premultiply(VipsImage *in, VipsImage **out, double scale) {
   if (vips_premultiply(in, &tmp1, NULL))
     return 1;
 
-	if (vips_resize(tmp1, &tmp2, scale, NULL)) {
+	if (vips_resize(tmp1, &tmp2, wscale, "vscale", hscale, NULL)) {
     clear_image(&tmp1);
 		return 1;
   }
@@ -367,6 +437,25 @@ vips_flip_horizontal_go(VipsImage *in, VipsImage **out) {
   return vips_flip(in, out, VIPS_DIRECTION_HORIZONTAL, NULL);
 }
 
+int
+vips_flip_vertical_go(VipsImage *in, VipsImage **out) {
+  return vips_flip(in, out, VIPS_DIRECTION_VERTICAL, NULL);
+}
+
+int
+vips_similarity_rotate_go(VipsImage *in, VipsImage **out, double angle, double *bg, int bgn) {
+#if VIPS_SUPPORT_ROTATE_BACKGROUND
+  VipsArrayDouble *bga = vips_array_double_new(bg, bgn);
+  // vips_similarity rotates anticlockwise, but we want to rotate clockwise
+  int ret = vips_similarity(in, out, "angle", -angle, "background", bga, NULL);
+  vips_area_unref((VipsArea *)bga);
+  return ret;
+#else
+  vips_error("vips_similarity_rotate_go", "Rotation by arbitrary angle is not supported (libvips 8.7+ reuired)");
+  return 1;
+#endif
+}
+
 int
 vips_smartcrop_go(VipsImage *in, VipsImage **out, int width, int height) {
 #if VIPS_SUPPORT_SMARTCROP
@@ -387,6 +476,228 @@ vips_sharpen_go(VipsImage *in, VipsImage **out, double sigma) {
   return vips_sharpen(in, out, "sigma", sigma, NULL);
 }
 
+int
+vips_pixelate_go(VipsImage *in, VipsImage **out, int size) {
+  VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+
+  int width = in->Xsize, height = in->Ysize;
+  int tw = (width + size - 1) / size * size;
+  int th = (height + size - 1) / size * size;
+
+  int res =
+    vips_embed(in, &t[0], 0, 0, tw, th, "extend", VIPS_EXTEND_COPY, NULL) ||
+    vips_shrink(t[0], &t[1], size, size, NULL) ||
+    vips_zoom(t[1], &t[2], size, size, NULL) ||
+    vips_extract_area(t[2], out, 0, 0, width, height, NULL);
+
+  clear_image(&base);
+
+  return res;
+}
+
+int
+vips_blur_area_go(VipsImage *in, VipsImage **out, int left, int top, int width, int height, double sigma) {
+  VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+
+  int res =
+    vips_extract_area(in, &t[0], left, top, width, height, NULL) ||
+    vips_gaussblur(t[0], &t[1], sigma, NULL) ||
+    vips_cast(t[1], &t[2], vips_image_get_format(in), NULL) ||
+    vips_insert(in, t[2], out, left, top, NULL);
+
+  clear_image(&base);
+
+  return res;
+}
+
+int
+vips_extract_color_bands(VipsImage *in, VipsImage **color, VipsImage **alpha) {
+  if (!vips_image_hasalpha_go(in)) {
+    *alpha = NULL;
+    return vips_copy(in, color, NULL);
+  }
+
+  if (vips_extract_band(in, color, 0, "n", in->Bands - 1, NULL))
+    return 1;
+
+  if (vips_extract_band(in, alpha, in->Bands - 1, "n", 1, NULL)) {
+    clear_image(color);
+    return 1;
+  }
+
+  return 0;
+}
+
+int
+vips_join_color_bands(VipsImage *in, VipsImage *color, VipsImage *alpha, VipsImage **out) {
+  VipsImage *tmp;
+
+  if (alpha == NULL) {
+    if (vips_copy(color, &tmp, NULL))
+      return 1;
+  } else if (vips_bandjoin2(color, alpha, &tmp, NULL))
+    return 1;
+
+  int res = vips_cast(tmp, out, vips_image_get_format(in), NULL);
+
+  clear_image(&tmp);
+
+  return res;
+}
+
+int
+vips_brightness_contrast_go(VipsImage *in, VipsImage **out, int brightness, double contrast) {
+  VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+
+  double b = brightness + 128.0 * (1.0 - contrast);
+
+  int res =
+    vips_extract_color_bands(in, &t[0], &t[1]) ||
+    vips_linear1(t[0], &t[2], contrast, b, NULL) ||
+    vips_join_color_bands(in, t[2], t[1], out);
+
+  clear_image(&base);
+
+  return res;
+}
+
+int
+vips_saturation_go(VipsImage *in, VipsImage **out, double saturation) {
+  VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 5);
+
+  double a[3] = {1.0, saturation, 1.0};
+  double b[3] = {0.0, 0.0, 0.0};
+
+  int res =
+    vips_extract_color_bands(in, &t[0], &t[1]) ||
+    vips_colourspace(t[0], &t[2], VIPS_INTERPRETATION_LCH, NULL) ||
+    vips_linear(t[2], &t[3], a, b, 3, NULL) ||
+    vips_colourspace(t[3], &t[4], in->Type, NULL) ||
+    vips_join_color_bands(in, t[4], t[1], out);
+
+  clear_image(&base);
+
+  return res;
+}
+
+int
+vips_gamma_go(VipsImage *in, VipsImage **out, double gamma) {
+  VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+
+  int res =
+    vips_extract_color_bands(in, &t[0], &t[1]) ||
+    vips_gamma(t[0], &t[2], "exponent", gamma, NULL) ||
+    vips_join_color_bands(in, t[2], t[1], out);
+
+  clear_image(&base);
+
+  return res;
+}
+
+int
+vips_recomb_go(VipsImage *in, VipsImage **out, double *matrix) {
+  VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 4);
+
+  t[2] = vips_image_new_from_memory_copy(matrix, 9 * sizeof(double), 3, 3, 1, VIPS_FORMAT_DOUBLE);
+
+  int res =
+    t[2] == NULL ||
+    vips_extract_color_bands(in, &t[0], &t[1]) ||
+    vips_recomb(t[0], &t[3], t[2], NULL) ||
+    vips_join_color_bands(in, t[3], t[1], out);
+
+  clear_image(&base);
+
+  return res;
+}
+
+int
+vips_duotone_go(VipsImage *in, VipsImage **out,
+                double sr, double sg, double sb, double hr, double hg, double hb) {
+  VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 8);
+
+  gboolean is16 = vips_image_get_format(in) == VIPS_FORMAT_USHORT;
+  double max = is16 ? 65535.0 : 255.0;
+  double scale = is16 ? 257.0 : 1.0;
+
+  double luma[3] = {0.2126, 0.7152, 0.0722};
+  double a[3] = {(hr - sr) * scale / max, (hg - sg) * scale / max, (hb - sb) * scale / max};
+  double b[3] = {sr * scale, sg * scale, sb * scale};
+
+  t[2] = vips_image_new_from_memory_copy(luma, 3 * sizeof(double), 3, 1, 1, VIPS_FORMAT_DOUBLE);
+
+  int res =
+    t[2] == NULL ||
+    vips_extract_color_bands(in, &t[0], &t[1]) ||
+    vips_recomb(t[0], &t[3], t[2], NULL) ||
+    vips_cast(t[3], &t[4], is16 ? VIPS_FORMAT_USHORT : VIPS_FORMAT_UCHAR, NULL) ||
+    vips_identity(&t[5], "ushort", is16, NULL) ||
+    vips_linear(t[5], &t[6], a, b, 3, NULL) ||
+    vips_maplut(t[4], &t[7], t[6], NULL) ||
+    vips_join_color_bands(in, t[7], t[1], out);
+
+  clear_image(&base);
+
+  return res;
+}
+
+int
+vips_rounded_mask_go(VipsImage *in, VipsImage **out, double half_width, double half_height, double radius) {
+  VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 17);
+
+  gboolean is16 = vips_image_get_format(in) == VIPS_FORMAT_USHORT;
+  double max_alpha = is16 ? 65535.0 : 255.0;
+
+  double center[2] = {0.5 - in->Xsize / 2.0, 0.5 - in->Ysize / 2.0};
+  double inner[2] = {radius - half_width, radius - half_height};
+  double ones[2] = {1.0, 1.0};
+
+  /* Distance from each pixel center to the rounded rectangle edge
+   * with a 1px linear falloff for antialiasing */
+  if (
+    vips_xyz(&t[0], in->Xsize, in->Ysize, NULL) ||
+    vips_linear(t[0], &t[1], ones, center, 2, NULL) ||
+    vips_abs(t[1], &t[2], NULL) ||
+    vips_linear(t[2], &t[3], ones, inner, 2, NULL) ||
+    vips_abs(t[3], &t[4], NULL) ||
+    vips_add(t[3], t[4], &t[5], NULL) ||
+    vips_linear1(t[5], &t[6], 0.5, 0, NULL) ||
+    vips_multiply(t[6], t[6], &t[7], NULL) ||
+    vips_bandmean(t[7], &t[8], NULL) ||
+    vips_linear1(t[8], &t[9], 2.0, 0, NULL) ||
+    vips_pow_const1(t[9], &t[10], 0.5, NULL) ||
+    vips_linear1(t[10], &t[16], -max_alpha, (radius + 0.5) * max_alpha, NULL) ||
+    vips_cast(t[16], &t[11], is16 ? VIPS_FORMAT_USHORT : VIPS_FORMAT_UCHAR, NULL) ||
+    vips_extract_color_bands(in, &t[12], &t[13])
+  ) {
+    clear_image(&base);
+    return 1;
+  }
+
+  int res;
+
+  if (t[13] == NULL) {
+    res = vips_join_color_bands(in, t[12], t[11], out);
+  } else {
+    res =
+      vips_multiply(t[13], t[11], &t[14], NULL) ||
+      vips_linear1(t[14], &t[15], 1.0 / max_alpha, 0, NULL) ||
+      vips_join_color_bands(in, t[12], t[15], out);
+  }
+
+  clear_image(&base);
+
+  return res;
+}
+
 int
 vips_flatten_go(VipsImage *in, VipsImage **out, double r, double g, double b) {
   VipsArrayDouble *bg = vips_array_double_newv(3, r, g, b);
@@ -515,6 +826,27 @@ vips_ensure_alpha(VipsImage *in, VipsImage **out) {
   return vips_bandjoin_const1(in, out, 255, NULL);
 }
 
+int
+vips_rgba_pixels_go(VipsImage *in, void **buf, size_t *len) {
+  VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+
+  if (
+    vips_colourspace(in, &t[0], VIPS_INTERPRETATION_sRGB, NULL) ||
+    vips_ensure_alpha(t[0], &t[1]) ||
+    vips_cast(t[1], &t[2], VIPS_FORMAT_UCHAR, NULL)
+  ) {
+    clear_image(&base);
+    return 1;
+  }
+
+  *buf = vips_image_write_to_memory(t[2], len);
+
+  clear_image(&base);
+
+  return *buf == NULL;
+}
+
 int
 vips_apply_watermark(VipsImage *in, VipsImage *watermark, VipsImage **out, double opacity) {
 #if VIPS_SUPPORT_COMPOSITE
@@ -551,6 +883,80 @@ vips_apply_watermark(VipsImage *in, VipsImage *watermark, VipsImage **out, doubl
 #endif
 }
 
+int
+vips_shadow_go(VipsImage *in, VipsImage **out, int width, int height, int x, int y,
+               double sigma, double r, double g, double b, double opacity) {
+  VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 8);
+
+  gboolean is16 = vips_image_get_format(in) == VIPS_FORMAT_USHORT;
+  double max_alpha = is16 ? 65535.0 : 255.0;
+  double scale = is16 ? 257.0 : 1.0;
+
+  double a[3] = {0.0, 0.0, 0.0};
+  double color[3] = {r * scale, g * scale, b * scale};
+
+  gboolean has_alpha = vips_image_hasalpha_go(in);
+
+  /* Shadow alpha is the image alpha or a solid rectangle if there's no alpha */
+  if (has_alpha) {
+    if (vips_extract_band(in, &t[0], in->Bands - 1, "n", 1, NULL)) {
+      clear_image(&base);
+      return 1;
+    }
+  } else if (vips_black(&t[0], in->Xsize, in->Ysize, NULL)) {
+    clear_image(&base);
+    return 1;
+  }
+
+  int res =
+    vips_linear1(t[0], &t[1], has_alpha ? opacity : 0, has_alpha ? 0 : max_alpha * opacity, NULL) ||
+    vips_embed(t[1], &t[2], x, y, width, height, NULL);
+
+  if (!res) {
+    if (sigma > 0)
+      res = vips_gaussblur(t[2], &t[3], sigma, NULL);
+    else
+      res = vips_copy(t[2], &t[3], NULL);
+  }
+
+  res = res ||
+    vips_linear(t[3], &t[4], a, color, 3, NULL) ||
+    vips_bandjoin2(t[4], t[3], &t[5], NULL) ||
+    vips_cast(t[5], &t[6], vips_image_get_format(in), NULL) ||
+    vips_copy(t[6], out, "interpretation", in->Type, NULL);
+
+  clear_image(&base);
+
+  return res;
+}
+
+int
+vips_text_go(VipsImage **out, const char *text, const char *font, int dpi, double r, double g, double b) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+
+  double color[3] = {r, g, b};
+
+  if (vips_text(&t[0], text, "font", font, "dpi", dpi, NULL)) {
+    clear_image(&base);
+    return 1;
+  }
+
+  if (!(t[1] = vips_image_new_from_image(t[0], color, 3))) {
+    clear_image(&base);
+    return 1;
+  }
+
+  int res =
+    vips_bandjoin2(t[1], t[0], &t[2], NULL) ||
+    vips_copy(t[2], out, "interpretation", VIPS_INTERPRETATION_sRGB, NULL);
+
+  clear_image(&base);
+
+  return res;
+}
+
 int
 vips_arrayjoin_go(VipsImage **in, VipsImage **out, int n) {
   return vips_arrayjoin(in, out, n, "across", 1, NULL);
@@ -600,6 +1006,38 @@ vips_tiffsave_go(VipsImage *in, void **buf, size_t *len, int quality) {
 #endif
 }
 
+int
+vips_avifsave_go(VipsImage *in, void **buf, size_t *len, int quality, int speed) {
+#if VIPS_SUPPORT_AVIF
+  return vips_heifsave_buffer(
+    in, buf, len,
+    "Q", quality,
+    "compression", VIPS_FOREIGN_HEIF_COMPRESSION_AV1,
+#if VIPS_SUPPORT_AVIF_SPEED
+    "speed", speed,
+#endif
+    NULL);
+#else
+  vips_error("vips_avifsave_go", "Saving AVIF is not supported (libvips 8.9+ reuired)");
+  return 1;
+#endif
+}
+
+int
+vips_jxlsave_go(VipsImage *in, void **buf, size_t *len, int quality, int effort, int lossless) {
+#if VIPS_SUPPORT_JXL
+  return vips_jxlsave_buffer(
+    in, buf, len,
+    "Q", quality,
+    "effort", effort,
+    "lossless", lossless,
+    NULL);
+#else
+  vips_error("vips_jxlsave_go", "Saving JPEG XL is not supported (libvips 8.11+ reuired)");
+  return 1;
+#endif
+}
+
 int
 vips_bmpsave_go(VipsImage *in, void **buf, size_t *len) {
 #if VIPS_SUPPORT_MAGICK
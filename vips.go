@@ -13,6 +13,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"html"
 	"os"
 	"runtime"
 	"unsafe"
@@ -35,6 +36,9 @@ var vipsConf struct {
 	PngInterlaced         C.int
 	PngQuantize           C.int
 	PngQuantizationColors C.int
+	AvifSpeed             C.int
+	JxlEffort             C.int
+	JxlLossless           C.int
 	WatermarkOpacity      C.double
 }
 
@@ -94,6 +98,14 @@ func initVips() error {
 
 	vipsConf.PngQuantizationColors = C.int(conf.PngQuantizationColors)
 
+	vipsConf.AvifSpeed = C.int(conf.AvifSpeed)
+
+	vipsConf.JxlEffort = C.int(conf.JxlEffort)
+
+	if conf.JxlLossless {
+		vipsConf.JxlLossless = C.int(1)
+	}
+
 	vipsConf.WatermarkOpacity = C.double(conf.WatermarkOpacity)
 
 	if err := vipsLoadWatermark(); err != nil {
@@ -148,7 +160,7 @@ func (img *vipsImage) Height() int {
 	return int(img.VipsImage.Ysize)
 }
 
-func (img *vipsImage) Load(data []byte, imgtype imageType, shrink int, scale float64, pages int) error {
+func (img *vipsImage) Load(data []byte, imgtype imageType, shrink int, scale float64, page, pages int) error {
 	var tmp *C.VipsImage
 
 	err := C.int(0)
@@ -159,19 +171,26 @@ func (img *vipsImage) Load(data []byte, imgtype imageType, shrink int, scale flo
 	case imageTypePNG:
 		err = C.vips_pngload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), &tmp)
 	case imageTypeWEBP:
-		err = C.vips_webpload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), C.double(scale), C.int(pages), &tmp)
+		err = C.vips_webpload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), C.double(scale), C.int(page), C.int(pages), &tmp)
 	case imageTypeGIF:
-		err = C.vips_gifload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), C.int(pages), &tmp)
+		err = C.vips_gifload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), C.int(page), C.int(pages), &tmp)
 	case imageTypeSVG:
 		err = C.vips_svgload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), C.double(scale), &tmp)
-	case imageTypeHEIC:
-		err = C.vips_heifload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), &tmp)
+	case imageTypePDF:
+		err = C.vips_pdfload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), C.int(page), C.double(scale), &tmp)
+	case imageTypeHEIC, imageTypeAVIF:
+		err = C.vips_heifload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), C.int(page), &tmp)
+	case imageTypeJXL:
+		err = C.vips_jxlload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), &tmp)
 	case imageTypeBMP:
 		err = C.vips_bmpload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), &tmp)
 	case imageTypeTIFF:
-		err = C.vips_tiffload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), &tmp)
+		err = C.vips_tiffload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), C.int(page), &tmp)
 	}
 	if err != 0 {
+		if imgtype == imageTypePDF && page > 0 && pdfPageOutOfRange(data, page) {
+			return errPageOutOfRange
+		}
 		return vipsError()
 	}
 
@@ -180,6 +199,26 @@ func (img *vipsImage) Load(data []byte, imgtype imageType, shrink int, scale flo
 	return nil
 }
 
+// pdfPageOutOfRange checks if the PDF document doesn't have the page.
+// PDF pages count is unknown until the document is loaded
+func pdfPageOutOfRange(data []byte, page int) bool {
+	var (
+		tmp    *C.VipsImage
+		nPages C.int
+	)
+
+	if C.vips_pdfload_go(unsafe.Pointer(&data[0]), C.size_t(len(data)), 0, 1, &tmp) != 0 {
+		return false
+	}
+	defer C.clear_image(&tmp)
+
+	if C.vips_image_get_int(tmp, cachedCString("n-pages"), &nPages) != 0 {
+		return false
+	}
+
+	return page >= int(nPages)
+}
+
 func (img *vipsImage) Save(imgtype imageType, quality int, stripMeta bool) ([]byte, context.CancelFunc, error) {
 	if imgtype == imageTypeICO {
 		b, err := img.SaveAsIco()
@@ -209,6 +248,10 @@ func (img *vipsImage) Save(imgtype imageType, quality int, stripMeta bool) ([]by
 		err = C.vips_bmpsave_go(img.VipsImage, &ptr, &imgsize)
 	case imageTypeTIFF:
 		err = C.vips_tiffsave_go(img.VipsImage, &ptr, &imgsize, C.int(quality))
+	case imageTypeAVIF:
+		err = C.vips_avifsave_go(img.VipsImage, &ptr, &imgsize, C.int(quality), vipsConf.AvifSpeed)
+	case imageTypeJXL:
+		err = C.vips_jxlsave_go(img.VipsImage, &ptr, &imgsize, C.int(quality), vipsConf.JxlEffort, vipsConf.JxlLossless)
 	}
 	if err != 0 {
 		C.g_free_go(&ptr)
@@ -220,6 +263,20 @@ func (img *vipsImage) Save(imgtype imageType, quality int, stripMeta bool) ([]by
 	return b, cancel, nil
 }
 
+// RGBAPixels returns the image pixels as 8-bit sRGB with alpha
+func (img *vipsImage) RGBAPixels() ([]byte, error) {
+	var ptr unsafe.Pointer
+	defer C.g_free_go(&ptr)
+
+	size := C.size_t(0)
+
+	if C.vips_rgba_pixels_go(img.VipsImage, &ptr, &size) != 0 {
+		return nil, vipsError()
+	}
+
+	return C.GoBytes(ptr, C.int(size)), nil
+}
+
 func (img *vipsImage) SaveAsIco() ([]byte, error) {
 	if img.Width() > 256 || img.Height() > 256 {
 		return nil, errors.New("Image dimensions is too big. Max dimension size for ICO is 256")
@@ -326,6 +383,37 @@ func (img *vipsImage) HasAlpha() bool {
 	return C.vips_image_hasalpha_go(img.VipsImage) > 0
 }
 
+func (img *vipsImage) HasICCProfile() bool {
+	return C.vips_has_embedded_icc(img.VipsImage) != 0
+}
+
+func (img *vipsImage) ColorspaceName() string {
+	switch img.VipsImage.Type {
+	case C.VIPS_INTERPRETATION_B_W:
+		return "b-w"
+	case C.VIPS_INTERPRETATION_GREY16:
+		return "grey16"
+	case C.VIPS_INTERPRETATION_sRGB:
+		return "srgb"
+	case C.VIPS_INTERPRETATION_scRGB:
+		return "scrgb"
+	case C.VIPS_INTERPRETATION_RGB:
+		return "rgb"
+	case C.VIPS_INTERPRETATION_RGB16:
+		return "rgb16"
+	case C.VIPS_INTERPRETATION_CMYK:
+		return "cmyk"
+	case C.VIPS_INTERPRETATION_LAB:
+		return "lab"
+	case C.VIPS_INTERPRETATION_XYZ:
+		return "xyz"
+	case C.VIPS_INTERPRETATION_HSV:
+		return "hsv"
+	default:
+		return "multiband"
+	}
+}
+
 func (img *vipsImage) GetInt(name string) (int, error) {
 	var i C.int
 
@@ -339,6 +427,43 @@ func (img *vipsImage) SetInt(name string, value int) {
 	C.vips_image_set_int(img.VipsImage, cachedCString(name), C.int(value))
 }
 
+func (img *vipsImage) GetIntSlice(name string) ([]int, error) {
+	var (
+		ptr  *C.int
+		size C.int
+	)
+
+	if C.vips_image_get_array_int_go(img.VipsImage, cachedCString(name), &ptr, &size) != 0 {
+		return nil, vipsError()
+	}
+
+	if size == 0 {
+		return []int{}, nil
+	}
+
+	cOut := (*[1 << 28]C.int)(unsafe.Pointer(ptr))[:int(size):int(size)]
+	out := make([]int, len(cOut))
+
+	for i, el := range cOut {
+		out[i] = int(el)
+	}
+
+	return out, nil
+}
+
+func (img *vipsImage) SetIntSlice(name string, value []int) {
+	if len(value) == 0 {
+		return
+	}
+
+	in := make([]C.int, len(value))
+	for i, el := range value {
+		in[i] = C.int(el)
+	}
+
+	C.vips_image_set_array_int_go(img.VipsImage, cachedCString(name), &in[0], C.int(len(value)))
+}
+
 func (img *vipsImage) CastUchar() error {
 	var tmp *C.VipsImage
 
@@ -365,15 +490,15 @@ func (img *vipsImage) Rad2Float() error {
 	return nil
 }
 
-func (img *vipsImage) Resize(scale float64, hasAlpa bool) error {
+func (img *vipsImage) Resize(wscale, hscale float64, hasAlpa bool) error {
 	var tmp *C.VipsImage
 
 	if hasAlpa {
-		if C.vips_resize_with_premultiply(img.VipsImage, &tmp, C.double(scale)) != 0 {
+		if C.vips_resize_with_premultiply(img.VipsImage, &tmp, C.double(wscale), C.double(hscale)) != 0 {
 			return vipsError()
 		}
 	} else {
-		if C.vips_resize_go(img.VipsImage, &tmp, C.double(scale)) != 0 {
+		if C.vips_resize_go(img.VipsImage, &tmp, C.double(wscale), C.double(hscale)) != 0 {
 			return vipsError()
 		}
 	}
@@ -411,6 +536,56 @@ func (img *vipsImage) Flip() error {
 	return nil
 }
 
+func (img *vipsImage) FlipVertical() error {
+	var tmp *C.VipsImage
+
+	if C.vips_flip_vertical_go(img.VipsImage, &tmp) != 0 {
+		return vipsError()
+	}
+
+	C.swap_and_clear(&img.VipsImage, tmp)
+	return nil
+}
+
+// maxAlpha returns the value of the fully opaque alpha for the image band format
+func (img *vipsImage) maxAlpha() float64 {
+	if img.VipsImage.BandFmt == C.VIPS_FORMAT_USHORT {
+		return 65535.0
+	}
+	return 255.0
+}
+
+func (img *vipsImage) RotateAngle(angle float64, bg rgbColor, transpBg bool) error {
+	var tmp *C.VipsImage
+
+	if err := img.RgbColourspace(); err != nil {
+		return err
+	}
+
+	var bgc []C.double
+	if transpBg {
+		if !img.HasAlpha() {
+			if C.vips_addalpha_go(img.VipsImage, &tmp) != 0 {
+				return vipsError()
+			}
+			C.swap_and_clear(&img.VipsImage, tmp)
+		}
+
+		bgc = []C.double{C.double(0)}
+	} else {
+		bgc = []C.double{C.double(bg.R), C.double(bg.G), C.double(bg.B), C.double(img.maxAlpha())}
+	}
+
+	bgn := minInt(int(img.VipsImage.Bands), len(bgc))
+
+	if C.vips_similarity_rotate_go(img.VipsImage, &tmp, C.double(angle), &bgc[0], C.int(bgn)) != 0 {
+		return vipsError()
+	}
+	C.swap_and_clear(&img.VipsImage, tmp)
+
+	return nil
+}
+
 func (img *vipsImage) Crop(left, top, width, height int) error {
 	var tmp *C.VipsImage
 
@@ -468,6 +643,17 @@ func (img *vipsImage) EnsureAlpha() error {
 	return nil
 }
 
+func (img *vipsImage) RoundedMask(halfWidth, halfHeight, radius float64) error {
+	var tmp *C.VipsImage
+
+	if C.vips_rounded_mask_go(img.VipsImage, &tmp, C.double(halfWidth), C.double(halfHeight), C.double(radius)) != 0 {
+		return vipsError()
+	}
+
+	C.swap_and_clear(&img.VipsImage, tmp)
+	return nil
+}
+
 func (img *vipsImage) Flatten(bg rgbColor) error {
 	var tmp *C.VipsImage
 
@@ -479,6 +665,70 @@ func (img *vipsImage) Flatten(bg rgbColor) error {
 	return nil
 }
 
+func (img *vipsImage) BrightnessContrast(brightness int, contrast float64) error {
+	var tmp *C.VipsImage
+
+	if C.vips_brightness_contrast_go(img.VipsImage, &tmp, C.int(brightness), C.double(contrast)) != 0 {
+		return vipsError()
+	}
+
+	C.swap_and_clear(&img.VipsImage, tmp)
+	return nil
+}
+
+func (img *vipsImage) Saturation(saturation float64) error {
+	var tmp *C.VipsImage
+
+	if C.vips_saturation_go(img.VipsImage, &tmp, C.double(saturation)) != 0 {
+		return vipsError()
+	}
+
+	C.swap_and_clear(&img.VipsImage, tmp)
+	return nil
+}
+
+func (img *vipsImage) Gamma(gamma float64) error {
+	var tmp *C.VipsImage
+
+	if C.vips_gamma_go(img.VipsImage, &tmp, C.double(gamma)) != 0 {
+		return vipsError()
+	}
+
+	C.swap_and_clear(&img.VipsImage, tmp)
+	return nil
+}
+
+func (img *vipsImage) Recomb(matrix [9]float64) error {
+	var tmp *C.VipsImage
+
+	var cmatrix [9]C.double
+	for i, v := range matrix {
+		cmatrix[i] = C.double(v)
+	}
+
+	if C.vips_recomb_go(img.VipsImage, &tmp, &cmatrix[0]) != 0 {
+		return vipsError()
+	}
+
+	C.swap_and_clear(&img.VipsImage, tmp)
+	return nil
+}
+
+func (img *vipsImage) Duotone(shadow, highlight rgbColor) error {
+	var tmp *C.VipsImage
+
+	if C.vips_duotone_go(
+		img.VipsImage, &tmp,
+		C.double(shadow.R), C.double(shadow.G), C.double(shadow.B),
+		C.double(highlight.R), C.double(highlight.G), C.double(highlight.B),
+	) != 0 {
+		return vipsError()
+	}
+
+	C.swap_and_clear(&img.VipsImage, tmp)
+	return nil
+}
+
 func (img *vipsImage) Blur(sigma float32) error {
 	var tmp *C.VipsImage
 
@@ -490,6 +740,28 @@ func (img *vipsImage) Blur(sigma float32) error {
 	return nil
 }
 
+func (img *vipsImage) Pixelate(size int) error {
+	var tmp *C.VipsImage
+
+	if C.vips_pixelate_go(img.VipsImage, &tmp, C.int(size)) != 0 {
+		return vipsError()
+	}
+
+	C.swap_and_clear(&img.VipsImage, tmp)
+	return nil
+}
+
+func (img *vipsImage) BlurArea(left, top, width, height int, sigma float32) error {
+	var tmp *C.VipsImage
+
+	if C.vips_blur_area_go(img.VipsImage, &tmp, C.int(left), C.int(top), C.int(width), C.int(height), C.double(sigma)) != 0 {
+		return vipsError()
+	}
+
+	C.swap_and_clear(&img.VipsImage, tmp)
+	return nil
+}
+
 func (img *vipsImage) Sharpen(sigma float32) error {
 	var tmp *C.VipsImage
 
@@ -608,7 +880,7 @@ func (img *vipsImage) Embed(width, height int, offX, offY int, bg rgbColor, tran
 
 		bgc = []C.double{C.double(0)}
 	} else {
-		bgc = []C.double{C.double(bg.R), C.double(bg.G), C.double(bg.B), 1.0}
+		bgc = []C.double{C.double(bg.R), C.double(bg.G), C.double(bg.B), C.double(img.maxAlpha())}
 	}
 
 	bgn := minInt(int(img.VipsImage.Bands), len(bgc))
@@ -621,6 +893,49 @@ func (img *vipsImage) Embed(width, height int, offX, offY int, bg rgbColor, tran
 	return nil
 }
 
+// Shadow places the image on a transparent canvas of the given size at (imgX, imgY)
+// and draws the shadow under it at (shadowX, shadowY)
+func (img *vipsImage) Shadow(width, height, imgX, imgY, shadowX, shadowY int, sigma float64, color rgbColor, opacity float64) error {
+	var shadow, tmp *C.VipsImage
+
+	if C.vips_shadow_go(
+		img.VipsImage, &shadow, C.int(width), C.int(height), C.int(shadowX), C.int(shadowY),
+		C.double(sigma), C.double(color.R), C.double(color.G), C.double(color.B), C.double(opacity),
+	) != 0 {
+		return vipsError()
+	}
+	defer C.clear_image(&shadow)
+
+	if err := img.Embed(width, height, imgX, imgY, rgbColor{}, true); err != nil {
+		return err
+	}
+
+	if C.vips_apply_watermark(shadow, img.VipsImage, &tmp, 1) != 0 {
+		return vipsError()
+	}
+	C.swap_and_clear(&img.VipsImage, tmp)
+
+	return nil
+}
+
+func (img *vipsImage) Text(text, font string, color rgbColor) error {
+	var tmp *C.VipsImage
+
+	// vips_text treats the text as Pango markup, so we escape it to render as is
+	ctext := C.CString(html.EscapeString(text))
+	defer C.free(unsafe.Pointer(ctext))
+
+	cfont := C.CString(font)
+	defer C.free(unsafe.Pointer(cfont))
+
+	if C.vips_text_go(&tmp, ctext, cfont, 72, C.double(color.R), C.double(color.G), C.double(color.B)) != 0 {
+		return vipsError()
+	}
+	C.swap_and_clear(&img.VipsImage, tmp)
+
+	return nil
+}
+
 func (img *vipsImage) ApplyWatermark(wm *vipsImage, opacity float64) error {
 	var tmp *C.VipsImage
 
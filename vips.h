@@ -14,7 +14,13 @@ enum ImgproxyImageTypes {
   SVG,
   HEIC,
   BMP,
-  TIFF
+  TIFF,
+  AVIF,
+  BLURHASH,
+  THUMBHASH,
+  PALETTE,
+  JXL,
+  PDF
 };
 
 int vips_initialize();
@@ -29,12 +35,14 @@ int vips_type_find_save_go(int imgtype);
 
 int vips_jpegload_go(void *buf, size_t len, int shrink, VipsImage **out);
 int vips_pngload_go(void *buf, size_t len, VipsImage **out);
-int vips_webpload_go(void *buf, size_t len, double scale, int pages, VipsImage **out);
-int vips_gifload_go(void *buf, size_t len, int pages, VipsImage **out);
+int vips_webpload_go(void *buf, size_t len, double scale, int page, int pages, VipsImage **out);
+int vips_gifload_go(void *buf, size_t len, int page, int pages, VipsImage **out);
 int vips_svgload_go(void *buf, size_t len, double scale, VipsImage **out);
-int vips_heifload_go(void *buf, size_t len, VipsImage **out);
+int vips_pdfload_go(void *buf, size_t len, int page, double scale, VipsImage **out);
+int vips_heifload_go(void *buf, size_t len, int page, VipsImage **out);
+int vips_jxlload_go(void *buf, size_t len, VipsImage **out);
 int vips_bmpload_go(void *buf, size_t len, VipsImage **out);
-int vips_tiffload_go(void *buf, size_t len, VipsImage **out);
+int vips_tiffload_go(void *buf, size_t len, int page, VipsImage **out);
 
 int vips_get_orientation(VipsImage *image);
 void vips_strip_meta(VipsImage *image);
@@ -46,6 +54,9 @@ VipsBandFormat vips_band_format(VipsImage *in);
 gboolean vips_support_webp_animation();
 gboolean vips_is_animated(VipsImage * in);
 
+int vips_image_get_array_int_go(VipsImage *image, const char *name, int **out, int *n);
+void vips_image_set_array_int_go(VipsImage *image, const char *name, const int *array, int n);
+
 gboolean vips_image_hasalpha_go(VipsImage * in);
 int vips_addalpha_go(VipsImage *in, VipsImage **out);
 
@@ -54,8 +65,8 @@ int vips_copy_go(VipsImage *in, VipsImage **out);
 int vips_cast_go(VipsImage *in, VipsImage **out, VipsBandFormat format);
 int vips_rad2float_go(VipsImage *in, VipsImage **out);
 
-int vips_resize_go(VipsImage *in, VipsImage **out, double scale);
-int vips_resize_with_premultiply(VipsImage *in, VipsImage **out, double scale);
+int vips_resize_go(VipsImage *in, VipsImage **out, double wscale, double hscale);
+int vips_resize_with_premultiply(VipsImage *in, VipsImage **out, double wscale, double hscale);
 
 int vips_icc_is_srgb_iec61966(VipsImage *in);
 int vips_has_embedded_icc(VipsImage *in);
@@ -65,6 +76,8 @@ int vips_colourspace_go(VipsImage *in, VipsImage **out, VipsInterpretation cs);
 
 int vips_rot_go(VipsImage *in, VipsImage **out, VipsAngle angle);
 int vips_flip_horizontal_go(VipsImage *in, VipsImage **out);
+int vips_flip_vertical_go(VipsImage *in, VipsImage **out);
+int vips_similarity_rotate_go(VipsImage *in, VipsImage **out, double angle, double *bg, int bgn);
 
 int vips_extract_area_go(VipsImage *in, VipsImage **out, int left, int top, int width, int height);
 int vips_smartcrop_go(VipsImage *in, VipsImage **out, int width, int height);
@@ -74,15 +87,28 @@ int vips_trim(VipsImage *in, VipsImage **out, double threshold,
 
 int vips_gaussblur_go(VipsImage *in, VipsImage **out, double sigma);
 int vips_sharpen_go(VipsImage *in, VipsImage **out, double sigma);
-
+int vips_pixelate_go(VipsImage *in, VipsImage **out, int size);
+int vips_blur_area_go(VipsImage *in, VipsImage **out, int left, int top, int width, int height, double sigma);
+
+int vips_brightness_contrast_go(VipsImage *in, VipsImage **out, int brightness, double contrast);
+int vips_saturation_go(VipsImage *in, VipsImage **out, double saturation);
+int vips_gamma_go(VipsImage *in, VipsImage **out, double gamma);
+int vips_recomb_go(VipsImage *in, VipsImage **out, double *matrix);
+int vips_duotone_go(VipsImage *in, VipsImage **out,
+                    double sr, double sg, double sb, double hr, double hg, double hb);
+int vips_rounded_mask_go(VipsImage *in, VipsImage **out, double half_width, double half_height, double radius);
 int vips_flatten_go(VipsImage *in, VipsImage **out, double r, double g, double b);
 
 int vips_replicate_go(VipsImage *in, VipsImage **out, int across, int down);
 int vips_embed_go(VipsImage *in, VipsImage **out, int x, int y, int width, int height, double *bg, int bgn);
 
 int vips_ensure_alpha(VipsImage *in, VipsImage **out);
+int vips_rgba_pixels_go(VipsImage *in, void **buf, size_t *len);
 
+int vips_shadow_go(VipsImage *in, VipsImage **out, int width, int height, int x, int y,
+                   double sigma, double r, double g, double b, double opacity);
 int vips_apply_watermark(VipsImage *in, VipsImage *watermark, VipsImage **out, double opacity);
+int vips_text_go(VipsImage **out, const char *text, const char *font, int dpi, double r, double g, double b);
 
 int vips_arrayjoin_go(VipsImage **in, VipsImage **out, int n);
 
@@ -93,5 +119,7 @@ int vips_gifsave_go(VipsImage *in, void **buf, size_t *len);
 int vips_icosave_go(VipsImage *in, void **buf, size_t *len);
 int vips_bmpsave_go(VipsImage *in, void **buf, size_t *len);
 int vips_tiffsave_go(VipsImage *in, void **buf, size_t *len, int quality);
+int vips_avifsave_go(VipsImage *in, void **buf, size_t *len, int quality, int speed);
+int vips_jxlsave_go(VipsImage *in, void **buf, size_t *len, int quality, int effort, int lossless);
 
 void vips_cleanup();
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const watermarkDataCtxKey = ctxKey("watermarkData")
+
+var watermarksCache *watermarkCache
+
+type watermarkCacheItem struct {
+	url       string
+	data      *imageData
+	createdAt time.Time
+}
+
+type watermarkCache struct {
+	maxSize int
+	ttl     time.Duration
+	items   map[string]*list.Element
+	lru     *list.List
+
+	mutex sync.Mutex
+}
+
+func initWatermarksCache() {
+	if conf.WatermarksCacheSize > 0 {
+		watermarksCache = newWatermarkCache(
+			conf.WatermarksCacheSize,
+			time.Duration(conf.WatermarksCacheTTL)*time.Second,
+		)
+	}
+}
+
+func newWatermarkCache(maxSize int, ttl time.Duration) *watermarkCache {
+	return &watermarkCache{
+		maxSize: maxSize,
+		ttl:     ttl,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *watermarkCache) Get(url string) (*imageData, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[url]
+	if !ok {
+		return nil, false
+	}
+
+	item := el.Value.(*watermarkCacheItem)
+
+	// Expired watermarks are downloaded again so updated ones are picked up
+	if time.Since(item.createdAt) > c.ttl {
+		c.lru.Remove(el)
+		delete(c.items, url)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	return item.data, true
+}
+
+func (c *watermarkCache) Set(url string, data *imageData) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[url]; ok {
+		c.lru.MoveToFront(el)
+		item := el.Value.(*watermarkCacheItem)
+		item.data = data
+		item.createdAt = time.Now()
+		return
+	}
+
+	c.items[url] = c.lru.PushFront(&watermarkCacheItem{url: url, data: data, createdAt: time.Now()})
+
+	for c.lru.Len() > c.maxSize {
+		item := c.lru.Remove(c.lru.Back()).(*watermarkCacheItem)
+		delete(c.items, item.url)
+	}
+}
+
+func getWatermarkByURL(url string) (*imageData, error) {
+	if watermarksCache != nil {
+		if data, ok := watermarksCache.Get(url); ok {
+			return data, nil
+		}
+	}
+
+	imgdata, err := remoteImageData(url, "watermark")
+	if err != nil {
+		return nil, err
+	}
+	defer imgdata.Close()
+
+	// Download buffer returns to the pool on close, so we need a copy to keep
+	data := &imageData{
+		Data: append([]byte(nil), imgdata.Data...),
+		Type: imgdata.Type,
+	}
+
+	if watermarksCache != nil {
+		watermarksCache.Set(url, data)
+	}
+
+	return data, nil
+}
+
+func downloadWatermarks(ctx context.Context) (context.Context, error) {
+	po := getProcessingOptions(ctx)
+
+	var wmData map[string]*imageData
+
+	for _, wm := range po.Watermarks {
+		if !wm.Enabled || len(wm.URL) == 0 {
+			continue
+		}
+
+		if _, ok := wmData[wm.URL]; ok {
+			continue
+		}
+
+		data, err := getWatermarkByURL(wm.URL)
+		if err != nil {
+			return ctx, newError(404, err.Error(), msgSourceImageIsUnreachable).SetUnexpected(conf.ReportDownloadingErrors)
+		}
+
+		if wmData == nil {
+			wmData = make(map[string]*imageData)
+		}
+		wmData[wm.URL] = data
+	}
+
+	if wmData == nil {
+		return ctx, nil
+	}
+
+	return context.WithValue(ctx, watermarkDataCtxKey, wmData), nil
+}
+
+func getWatermark(ctx context.Context, opts *watermarkOptions) *imageData {
+	if len(opts.URL) > 0 {
+		wmData, _ := ctx.Value(watermarkDataCtxKey).(map[string]*imageData)
+		return wmData[opts.URL]
+	}
+	return watermark
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type WatermarksTestSuite struct{ MainTestSuite }
+
+func (s *WatermarksTestSuite) TestCacheHit() {
+	c := newWatermarkCache(2, time.Hour)
+
+	data := &imageData{Data: []byte("a"), Type: imageTypePNG}
+	c.Set("http://images.dev/a.png", data)
+
+	cached, ok := c.Get("http://images.dev/a.png")
+	require.True(s.T(), ok)
+	assert.Same(s.T(), data, cached)
+
+	_, ok = c.Get("http://images.dev/b.png")
+	assert.False(s.T(), ok)
+}
+
+func (s *WatermarksTestSuite) TestCacheEviction() {
+	c := newWatermarkCache(2, time.Hour)
+
+	c.Set("a", &imageData{})
+	c.Set("b", &imageData{})
+
+	// Touch "a" so "b" becomes the least recently used
+	_, ok := c.Get("a")
+	require.True(s.T(), ok)
+
+	c.Set("c", &imageData{})
+
+	_, ok = c.Get("a")
+	assert.True(s.T(), ok)
+
+	_, ok = c.Get("b")
+	assert.False(s.T(), ok)
+
+	_, ok = c.Get("c")
+	assert.True(s.T(), ok)
+}
+
+func (s *WatermarksTestSuite) TestCacheTTL() {
+	c := newWatermarkCache(2, time.Minute)
+
+	c.Set("a", &imageData{})
+	c.items["a"].Value.(*watermarkCacheItem).createdAt = time.Now().Add(-time.Hour)
+
+	_, ok := c.Get("a")
+	assert.False(s.T(), ok)
+	assert.NotContains(s.T(), c.items, "a")
+	assert.Equal(s.T(), 0, c.lru.Len())
+
+	// Setting the watermark again makes it fresh
+	c.Set("a", &imageData{})
+
+	_, ok = c.Get("a")
+	assert.True(s.T(), ok)
+}
+
+func TestWatermarks(t *testing.T) {
+	suite.Run(t, new(WatermarksTestSuite))
+}